@@ -0,0 +1,163 @@
+package password
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidArgon2Hash is returned when a hash is not a valid argon2 PHC string.
+var ErrInvalidArgon2Hash = errors.New("invalid argon2 hash")
+
+// Argon2Params is the parameters of argon2id hashing.
+type Argon2Params struct {
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params is the parameters recommended by RFC 9106 for memory constrained environments.
+var DefaultArgon2Params = Argon2Params{Memory: 64 * 1024, Time: 3, Threads: 4, SaltLen: 16, KeyLen: 32}
+
+var argon2Encoding = base64.RawStdEncoding.Strict()
+
+// Limits of the argon2 parameters of compared hashes, so that a corrupted or planted
+// hash such as m=4294967295 cannot make a comparison allocate terabytes or run for
+// hours. They admit the parameters recommended by RFC 9106, the largest of which uses
+// 2 GiB of memory and one pass.
+const (
+	maxArgon2Memory = 2 << 20 // KiB
+	maxArgon2Work   = 4 << 20 // Memory * Time
+)
+
+func (p Argon2Params) tooCostly() bool {
+	return p.Memory > maxArgon2Memory || uint64(p.Memory)*uint64(p.Time) > maxArgon2Work
+}
+
+type argon2Hash struct {
+	variant string
+	params  Argon2Params
+	salt    []byte
+	key     []byte
+}
+
+// parseArgon2 parses a PHC string such as
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+// as produced by libargon2 and its bindings (PHP password_hash, Python argon2-cffi).
+func parseArgon2(s string) (*argon2Hash, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return nil, ErrInvalidArgon2Hash
+	}
+	h := new(argon2Hash)
+	switch h.variant = parts[1]; h.variant {
+	case "argon2id", "argon2i":
+	default:
+		return nil, fmt.Errorf("unsupported argon2 variant %q", h.variant)
+	}
+	if parts[2] != "v=19" {
+		return nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	params := strings.Split(parts[3], ",")
+	if len(params) != 3 {
+		return nil, ErrInvalidArgon2Hash
+	}
+	for i, name := range []string{"m", "t", "p"} {
+		v, ok := strings.CutPrefix(params[i], name+"=")
+		if !ok || v == "" || (len(v) > 1 && v[0] == '0') {
+			return nil, ErrInvalidArgon2Hash
+		}
+		bitSize := 32
+		if name == "p" {
+			bitSize = 8
+		}
+		n, err := strconv.ParseUint(v, 10, bitSize)
+		if err != nil || n == 0 {
+			return nil, ErrInvalidArgon2Hash
+		}
+		switch name {
+		case "m":
+			h.params.Memory = uint32(n)
+		case "t":
+			h.params.Time = uint32(n)
+		case "p":
+			h.params.Threads = uint8(n)
+		}
+	}
+	var err error
+	if h.salt, err = argon2Encoding.DecodeString(parts[4]); err != nil || len(h.salt) < 8 {
+		return nil, ErrInvalidArgon2Hash
+	}
+	if h.key, err = argon2Encoding.DecodeString(parts[5]); err != nil || len(h.key) < 4 {
+		return nil, ErrInvalidArgon2Hash
+	}
+	h.params.SaltLen, h.params.KeyLen = uint32(len(h.salt)), uint32(len(h.key))
+	return h, nil
+}
+
+func (h *argon2Hash) derive(password []byte) []byte {
+	if h.variant == "argon2i" {
+		return argon2.Key(password, h.salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLen)
+	}
+	return argon2.IDKey(password, h.salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLen)
+}
+
+func (h *argon2Hash) String() string {
+	return fmt.Sprintf(
+		"$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		h.variant, argon2.Version,
+		h.params.Memory, h.params.Time, h.params.Threads,
+		argon2Encoding.EncodeToString(h.salt), argon2Encoding.EncodeToString(h.key),
+	)
+}
+
+// HashArgon2 returns the argon2id hash of the password in PHC string format.
+func HashArgon2(password string, params Argon2Params) (string, error) {
+	if params.Memory == 0 || params.Time == 0 || params.Threads == 0 || params.SaltLen < 8 || params.KeyLen < 4 {
+		return "", errors.New("invalid argon2 parameters")
+	}
+	if params.tooCostly() {
+		return "", ErrHashCostTooHigh
+	}
+	h := &argon2Hash{variant: "argon2id", params: params, salt: make([]byte, params.SaltLen)}
+	if _, err := io.ReadFull(random, h.salt); err != nil {
+		return "", err
+	}
+	h.key = h.derive([]byte(password))
+	return h.String(), nil
+}
+
+// SetArgon2Params makes HashPassword return argon2id hashes with params instead of
+// bcrypt hashes, nil restores bcrypt. Existing hashes of either kind are still verified.
+//
+// A pepper is applied as for bcrypt, by hashing the HMAC-SHA256 of the password, not
+// with the argon2 secret input, which golang.org/x/crypto/argon2 does not expose.
+// Other implementations verify such hashes by hashing the same base64 (raw standard
+// encoding) HMAC; the PHC string records neither the pepper nor that one was used.
+func (p *Passworder) SetArgon2Params(params *Argon2Params) { p.argon2 = params }
+
+// compareArgon2 compares an argon2 PHC hash with its possible plaintext equivalent.
+// It returns bcrypt.ErrMismatchedHashAndPassword on mismatch, like bcrypt.CompareHashAndPassword,
+// and ErrHashCostTooHigh without hashing if the parameters exceed the limits above.
+func compareArgon2(hash, password string) error {
+	h, err := parseArgon2(hash)
+	if err != nil {
+		return err
+	}
+	if h.params.tooCostly() {
+		return ErrHashCostTooHigh
+	}
+	if subtle.ConstantTimeCompare(h.derive([]byte(password)), h.key) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
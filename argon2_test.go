@@ -0,0 +1,88 @@
+package password
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestArgon2Vectors(t *testing.T) {
+	for _, tc := range []struct{ name, password, hash string }{
+		// Python argon2-cffi documentation example.
+		{"argon2-cffi", "correct horse battery staple", "$argon2id$v=19$m=65536,t=3,p=4$MIIRqgvgQbgj220jfp0MPA$YfwJSVjtjSU0zzV/P3S9nnQ/USre2wvJMjfCIjrTQbg"},
+		// PHP manual password_hash example with PASSWORD_ARGON2I.
+		{"php", "rasmuslerdorf", "$argon2i$v=19$m=1024,t=2,p=2$YzJBSzV4TUhkMzc3d3laeg$zqU/1IN0/AogfP4cmSJI1vc8lpXRW9/S0sYY2i2jHT0"},
+		// libargon2 reference test vectors, which both of the above bind to.
+		{"ref-argon2i", "password", "$argon2i$v=19$m=65536,t=2,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG"},
+		{"ref-argon2id", "password", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"ref-argon2id-256", "password", "$argon2id$v=19$m=256,t=2,p=1$c29tZXNhbHQ$nf65EOgLrQMR/uIPnA4rEsF5h7TKyQwu9U1bMCHGi/4"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := compareArgon2(tc.hash, tc.password); err != nil {
+				t.Fatal(err)
+			}
+			if err := compareArgon2(tc.hash, tc.password+"x"); err == nil {
+				t.Fatal("expected mismatch; got nil")
+			}
+			h, err := parseArgon2(tc.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := h.String(); s != tc.hash {
+				t.Errorf("expected %s; got %s", tc.hash, s)
+			}
+		})
+	}
+}
+
+func TestArgon2Strict(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ",
+		"$argon2d$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$t=2,m=65536,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=065536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=1,keyid=abc$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ=$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc=",
+	} {
+		if _, err := parseArgon2(hash); err == nil {
+			t.Errorf("%s: expected error; got nil", hash)
+		}
+	}
+}
+
+func TestArgon2CostLimit(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2id$v=19$m=4294967295,t=1,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=4294967295,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+	} {
+		if err := compareArgon2(hash, "password"); err != ErrHashCostTooHigh {
+			t.Errorf("%s: expected ErrHashCostTooHigh; got %v", hash, err)
+		}
+		if err := New(time.Minute, 5, nil).CompareHashAndPassword("id", hash, "password"); !errors.Is(err, ErrHashCostTooHigh) {
+			t.Errorf("%s: expected ErrHashCostTooHigh; got %v", hash, err)
+		}
+	}
+	if _, err := HashArgon2("password", Argon2Params{Memory: 4 << 20, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}); err != ErrHashCostTooHigh {
+		t.Errorf("expected ErrHashCostTooHigh; got %v", err)
+	}
+}
+
+func TestArgon2Compare(t *testing.T) {
+	hash, err := HashArgon2("password", Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, nil)
+	if err := p.CompareHashAndPassword("", hash, "password"); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareHashAndPassword("", hash, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if v, _ := p.cache.Get(""); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
+	}
+}
@@ -0,0 +1,52 @@
+package password
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// HashResult is the result of hashing the password at Index of HashPasswordAsync.
+type HashResult struct {
+	Index int
+	Hash  string
+	Err   error
+}
+
+// HashPasswordAsync hashes passwords with HashPassword, at most limit at a time
+// (GOMAXPROCS if limit is not positive), and sends each result on the returned
+// channel as it completes, so not in order. The channel is closed when all are
+// done. Once ctx is done, the remaining passwords are reported with ctx.Err()
+// without being hashed. The channel must be drained.
+func (p *Passworder) HashPasswordAsync(ctx context.Context, passwords []string, limit int) <-chan HashResult {
+	if limit <= 0 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+	c := make(chan HashResult, limit)
+	sem := make(chan struct{}, limit)
+	go func() {
+		var wg sync.WaitGroup
+		for i, password := range passwords {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				c <- HashResult{Index: i, Err: ctx.Err()}
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				<-sem
+				c <- HashResult{Index: i, Err: err}
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				hash, err := p.HashPassword(password)
+				c <- HashResult{Index: i, Hash: hash, Err: err}
+			}()
+		}
+		wg.Wait()
+		close(c)
+	}()
+	return c
+}
@@ -0,0 +1,42 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHashPasswordAsync(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	passwords := []string{"a", "b", "c", "d", "e"}
+	seen := make(map[int]bool)
+	for r := range p.HashPasswordAsync(context.Background(), passwords, 2) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if seen[r.Index] {
+			t.Errorf("duplicate result for %d", r.Index)
+		}
+		seen[r.Index] = true
+		if err := p.CompareHashAndPassword("id", r.Hash, passwords[r.Index]); err != nil {
+			t.Errorf("%d: %v", r.Index, err)
+		}
+	}
+	if len(seen) != len(passwords) {
+		t.Errorf("expected %d results; got %d", len(passwords), len(seen))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var n int
+	for r := range p.HashPasswordAsync(ctx, passwords, 1) {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("expected context.Canceled; got %v", r.Err)
+		}
+		n++
+	}
+	if n != len(passwords) {
+		t.Errorf("expected %d results; got %d", len(passwords), n)
+	}
+}
@@ -0,0 +1,66 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func BenchmarkCompare(b *testing.B) {
+	p := New(24*time.Hour, 5, nil)
+	b.ReportAllocs()
+	for range b.N {
+		if err := p.Compare("id", "password", "password"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompareHashAndPassword(b *testing.B) {
+	for _, cost := range []int{bcrypt.MinCost, 8, bcrypt.DefaultCost, 12} {
+		b.Run(fmt.Sprintf("cost=%d", cost), func(b *testing.B) {
+			p := New(24*time.Hour, 5, nil)
+			p.SetCost(cost)
+			hash, err := p.HashPassword("password")
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if err := p.CompareHashAndPassword("id", hash, "password"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCompareRSA(b *testing.B) {
+	for _, bits := range []int{1024, 2048, 3072, 4096} {
+		b.Run(fmt.Sprintf("bits=%d", bits), func(b *testing.B) {
+			priv, err := rsa.GenerateKey(rand.Reader, bits)
+			if err != nil {
+				b.Fatal(err)
+			}
+			ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, []byte("password"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			encrypted := base64.StdEncoding.EncodeToString(ciphertext)
+			p := New(24*time.Hour, 5, priv)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if err := p.Compare("id", "password", encrypted); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
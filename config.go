@@ -0,0 +1,101 @@
+package password
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config is the configuration of a Passworder, see NewPassworder.
+type Config struct {
+	// Duration is how long incorrect password attempts are remembered.
+	Duration time.Duration
+	// MaxAttempts is the number of incorrect password attempts before lockout.
+	MaxAttempts int
+	// Store keeps the attempts records, nil means in memory.
+	Store Store
+
+	// Key decrypts passwords encrypted by clients, Encoding is the encoding
+	// of the ciphertext, nil means StdEncoding.
+	Key      *rsa.PrivateKey
+	Encoding Encoding
+
+	// Algorithm is the algorithm of new hashes, "bcrypt" (the default) or "argon2id".
+	Algorithm string
+	// Cost is the bcrypt cost, zero means bcrypt.MinCost.
+	Cost int
+	// Argon2 is the argon2id parameters, nil means DefaultArgon2Params.
+	Argon2 *Argon2Params
+
+	// Peppers is the ordered list of peppers, see SetPeppers.
+	Peppers [][]byte
+	// Policy is the requirements of new passwords, see SetPolicy.
+	Policy Policy
+	// Logger logs lockouts and decryption failures, nil disables logging.
+	Logger *slog.Logger
+}
+
+func (c Config) validate() error {
+	if c.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+	if c.MaxAttempts <= 0 {
+		return errors.New("max attempts must be positive")
+	}
+	switch c.Algorithm {
+	case "", "bcrypt":
+		if c.Argon2 != nil {
+			return errors.New("argon2 parameters given with bcrypt algorithm")
+		}
+		if c.Cost != 0 && (c.Cost < bcrypt.MinCost || c.Cost > bcrypt.MaxCost) {
+			return fmt.Errorf("bcrypt cost %d out of range [%d, %d]", c.Cost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case "argon2id":
+		if c.Cost != 0 {
+			return errors.New("bcrypt cost given with argon2id algorithm")
+		}
+		if params := c.Argon2; params != nil &&
+			(params.Memory == 0 || params.Time == 0 || params.Threads == 0 || params.SaltLen < 8 || params.KeyLen < 4) {
+			return errors.New("invalid argon2 parameters")
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownAlgorithm, c.Algorithm)
+	}
+	if c.Policy.MinScore < 0 || c.Policy.MinScore > 4 {
+		return fmt.Errorf("policy min score %d out of range [0, 4]", c.Policy.MinScore)
+	}
+	return nil
+}
+
+// NewPassworder returns a Passworder configured by cfg,
+// or an error if cfg is invalid or inconsistent.
+func NewPassworder(cfg Config) (*Passworder, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	p := New(cfg.Duration, cfg.MaxAttempts, cfg.Key)
+	if cfg.Store != nil {
+		p.SetStore(cfg.Store)
+	}
+	p.SetEncoding(cfg.Encoding)
+	if cfg.Cost != 0 {
+		p.SetCost(cfg.Cost)
+	}
+	if cfg.Algorithm == "argon2id" {
+		params := DefaultArgon2Params
+		if cfg.Argon2 != nil {
+			params = *cfg.Argon2
+		}
+		p.SetArgon2Params(&params)
+	}
+	if cfg.Peppers != nil {
+		p.SetPeppers(cfg.Peppers)
+	}
+	p.SetPolicy(cfg.Policy)
+	p.SetLogger(cfg.Logger)
+	return p, nil
+}
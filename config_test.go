@@ -0,0 +1,55 @@
+package password
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewPassworder(t *testing.T) {
+	for i, cfg := range []Config{
+		{},
+		{Duration: time.Hour},
+		{Duration: time.Hour, MaxAttempts: 5, Algorithm: "md5"},
+		{Duration: time.Hour, MaxAttempts: 5, Argon2: &DefaultArgon2Params},
+		{Duration: time.Hour, MaxAttempts: 5, Algorithm: "argon2id", Cost: 10},
+		{Duration: time.Hour, MaxAttempts: 5, Algorithm: "argon2id", Argon2: &Argon2Params{}},
+		{Duration: time.Hour, MaxAttempts: 5, Cost: 40},
+		{Duration: time.Hour, MaxAttempts: 5, Policy: Policy{MinScore: 5}},
+	} {
+		if _, err := NewPassworder(cfg); err == nil {
+			t.Errorf("#%d: expected error; got nil", i)
+		}
+	}
+
+	p, err := NewPassworder(Config{
+		Duration:    time.Hour,
+		MaxAttempts: 3,
+		Algorithm:   "argon2id",
+		Argon2:      &Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32},
+		Peppers:     [][]byte{[]byte("pepper")},
+		Policy:      Policy{MinScore: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.HashPassword("password"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("expected ErrWeakPassword; got %v", err)
+	}
+	hash, err := p.HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alg, _ := Algorithm(hash); alg != "argon2id" {
+		t.Errorf("expected argon2id; got %s", alg)
+	}
+	if err := p.CompareHashAndPassword("id", hash, "correct horse battery staple"); err != nil {
+		t.Error(err)
+	}
+	for range 3 {
+		p.CompareHashAndPassword("id", hash, "wrongpassword")
+	}
+	if err := p.CompareHashAndPassword("id", hash, "correct horse battery staple"); !errors.Is(err, ErrMaxPasswordAttempts) {
+		t.Errorf("expected ErrMaxPasswordAttempts; got %v", err)
+	}
+}
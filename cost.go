@@ -0,0 +1,104 @@
+package password
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type adaptiveCost struct {
+	sync.Mutex
+	target   time.Duration
+	interval time.Duration
+	cost     int
+	updated  time.Time
+
+	calibrating chan struct{} // closed when the calibration in flight is done
+}
+
+// SetAdaptiveCost makes HashPassword pick the bcrypt cost with CalibrateCost(target),
+// recalibrated at most every interval, so that hashing keeps close to target as the
+// load of the machine changes. The cost is stored in each hash, so verification is not
+// affected. The cost never falls below the one set by SetCost, which should be raised
+// to a safe floor since a loaded machine calibrates to a lower cost.
+// Zero target disables it.
+func (p *Passworder) SetAdaptiveCost(target, interval time.Duration) {
+	p.adaptive.Lock()
+	defer p.adaptive.Unlock()
+	p.adaptive.target = target
+	p.adaptive.interval = interval
+	p.adaptive.updated = time.Time{}
+}
+
+// hashCost returns the bcrypt cost for HashPassword. A single caller calibrates at a
+// time, without holding the lock, while the others keep using the previous cost, or
+// wait for it if there is none yet.
+func (p *Passworder) hashCost() (int, error) {
+	a := &p.adaptive
+	for {
+		a.Lock()
+		if a.target <= 0 {
+			a.Unlock()
+			return p.cost, nil
+		}
+		stale := a.updated.IsZero() || time.Since(a.updated) >= a.interval
+		if !stale || a.calibrating != nil && !a.updated.IsZero() {
+			cost := max(a.cost, p.cost)
+			a.Unlock()
+			return cost, nil
+		}
+		if done := a.calibrating; done != nil {
+			a.Unlock()
+			<-done
+			continue
+		}
+		done, target := make(chan struct{}), a.target
+		a.calibrating = done
+		a.Unlock()
+
+		cost, err := CalibrateCost(target)
+		a.Lock()
+		// The result is dropped if SetAdaptiveCost changed the target meanwhile.
+		if err == nil && a.target == target {
+			a.cost, a.updated = cost, time.Now()
+		}
+		a.calibrating = nil
+		close(done)
+		a.Unlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// costUpToDate reports whether a bcrypt hash of cost needs no rehash. With adaptive cost,
+// it is a cost at least the last calibrated one, so that a lower calibration under load
+// does not flag hashes made before, or the SetCost floor until calibrated.
+func (p *Passworder) costUpToDate(cost int) bool {
+	p.adaptive.Lock()
+	defer p.adaptive.Unlock()
+	if p.adaptive.target <= 0 {
+		return cost == p.cost
+	}
+	if p.adaptive.updated.IsZero() {
+		return cost >= p.cost
+	}
+	return cost >= max(p.adaptive.cost, p.cost)
+}
+
+// SetMaxVerifyCost makes comparisons reject bcrypt hashes with a cost above n with
+// ErrHashCostTooHigh before hashing, so that a corrupted or planted hash such as
+// $2a$31$... cannot tie up the server for minutes. Zero n means no limit. The check
+// only applies to bcrypt: argon2 hashes are bounded by fixed parameter limits instead.
+func (p *Passworder) SetMaxVerifyCost(n int) { p.maxVerifyCost = n }
+
+func (p *Passworder) checkCost(hash string) error {
+	if p.maxVerifyCost <= 0 {
+		return nil
+	}
+	if cost, err := bcrypt.Cost([]byte(hash)); err == nil && cost > p.maxVerifyCost {
+		return ErrHashCostTooHigh
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package password
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAdaptiveCost(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetCost(6)
+	p.SetAdaptiveCost(time.Nanosecond, time.Hour)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, _ := bcrypt.Cost([]byte(hash)); cost != 6 {
+		t.Errorf("expected cost floor 6; got %d", cost)
+	}
+	updated := p.adaptive.updated
+	if updated.IsZero() {
+		t.Fatal("expected calibrated; got not")
+	}
+	if _, err := p.HashPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if p.adaptive.updated != updated {
+		t.Error("expected no recalibration within interval")
+	}
+	p.adaptive.cost = 8
+	if hash, err = p.HashPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if cost, _ := bcrypt.Cost([]byte(hash)); cost != 8 {
+		t.Errorf("expected 8; got %d", cost)
+	}
+	if err := p.CompareHashAndPassword("", hash, "password"); err != nil {
+		t.Error(err)
+	}
+	if ok, err := p.IsUpToDate(hash); err != nil || !ok {
+		t.Errorf("expected adaptive cost hash up to date; got %v, %v", ok, err)
+	}
+	p.adaptive.cost = 7
+	if ok, err := p.IsUpToDate(hash); err != nil || !ok {
+		t.Errorf("expected hash above a lower calibration up to date; got %v, %v", ok, err)
+	}
+	p.adaptive.cost = 9
+	if ok, _ := p.IsUpToDate(hash); ok {
+		t.Error("expected hash below the calibrated cost outdated; got up to date")
+	}
+}
+
+func TestAdaptiveCostCalibrating(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetAdaptiveCost(time.Nanosecond, time.Nanosecond)
+	done := make(chan struct{})
+	p.adaptive.cost, p.adaptive.updated, p.adaptive.calibrating = 7, time.Now().Add(-time.Hour), done
+	if cost, err := p.hashCost(); err != nil || cost != 7 {
+		t.Errorf("expected previous cost 7 without waiting; got %d, %v", cost, err)
+	}
+
+	p.adaptive.updated = time.Time{}
+	res := make(chan int)
+	go func() {
+		cost, _ := p.hashCost()
+		res <- cost
+	}()
+	select {
+	case cost := <-res:
+		t.Fatalf("expected to wait for the first calibration; got %d", cost)
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.adaptive.Lock()
+	p.adaptive.cost, p.adaptive.updated, p.adaptive.calibrating = 8, time.Now(), nil
+	p.adaptive.interval = time.Hour
+	close(done)
+	p.adaptive.Unlock()
+	if cost := <-res; cost != 8 {
+		t.Errorf("expected calibrated cost 8; got %d", cost)
+	}
+}
+
+func TestMaxVerifyCost(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetMaxVerifyCost(5)
+	if err := p.CompareHashAndPassword("id", string(hash), "password"); err != ErrHashCostTooHigh {
+		t.Errorf("expected ErrHashCostTooHigh; got %v", err)
+	}
+	if _, ok := p.Attempts("id"); ok {
+		t.Error("expected no attempts recorded")
+	}
+	p.SetMaxVerifyCost(6)
+	if err := p.CompareHashAndPassword("id", string(hash), "password"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,50 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/blowfish"
+)
+
+// bcryptEncoding is the base64 alphabet of bcrypt, without padding.
+var bcryptEncoding = base64.NewEncoding("./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789").WithPadding(base64.NoPadding)
+
+// HashPasswordDeterministic returns the $2a$ bcrypt hash of password with the given
+// 16 bytes salt and cost, so that the same inputs always give the same hash, e.g. for
+// golden files or test vectors shared with other implementations.
+//
+// NEVER USE IT IN PRODUCTION. A fixed or reused salt lets identical passwords be
+// spotted and attacked at once with precomputed tables, which is what the random salt
+// of HashPassword prevents. No pepper, normalization or policy is applied.
+func HashPasswordDeterministic(password string, salt []byte, cost int) (string, error) {
+	if len(salt) != 16 {
+		return "", errors.New("bcrypt salt must be 16 bytes")
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return "", bcrypt.InvalidCostError(cost)
+	}
+	if len(password) > 72 {
+		return "", ErrPasswordTooLong
+	}
+	// Like the C implementations, the key includes the trailing NUL.
+	key := append([]byte(password), 0)
+	c, err := blowfish.NewSaltedCipher(key, salt)
+	if err != nil {
+		return "", err
+	}
+	for range 1 << cost {
+		blowfish.ExpandKey(key, c)
+		blowfish.ExpandKey(salt, c)
+	}
+	data := []byte("OrpheanBeholderScryDoubt")
+	for i := 0; i < len(data); i += 8 {
+		for range 64 {
+			c.Encrypt(data[i:i+8], data[i:i+8])
+		}
+	}
+	// Only 23 of the 24 encrypted bytes are encoded, as by the C implementations.
+	return fmt.Sprintf("$2a$%02d$%s%s", cost, bcryptEncoding.EncodeToString(salt), bcryptEncoding.EncodeToString(data[:23])), nil
+}
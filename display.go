@@ -0,0 +1,44 @@
+package password
+
+import (
+	"errors"
+	"time"
+)
+
+// SetDuration sets how long failed attempts are remembered, for both the lockout
+// and the display counters.
+func (p *Passworder) SetDuration(d time.Duration) {
+	p.AttemptLimiter.SetDuration(d)
+	p.display.SetDuration(d)
+}
+
+// Close releases the resources of records, for both the lockout and the display
+// counters. A closed Passworder must not be reused.
+func (p *Passworder) Close() {
+	p.AttemptLimiter.Close()
+	p.display.Close()
+}
+
+// CompareHashAndPasswordWithDisplay is like CompareHashAndPassword, but an incorrect
+// password is also counted for displayID, e.g. the device of the attempt, while the
+// lockout is still enforced by id. The display counter never locks anything out,
+// see DisplayAttempts.
+func (p *Passworder) CompareHashAndPasswordWithDisplay(id, displayID any, hash, password string) error {
+	_, err := p.compare(id, hash, password, compareOptions{hash: true, display: displayID, hasDisplay: true})
+	return err
+}
+
+func (p *Passworder) countDisplay(displayID any, err error) {
+	if err == nil {
+		p.display.Reset(displayID)
+	} else if errors.Is(err, ErrIncorrectPassword) {
+		p.display.Fail(displayID)
+	}
+}
+
+// DisplayAttempts returns the failed attempts counted for displayID by
+// CompareHashAndPasswordWithDisplay, for reporting only.
+func (p *Passworder) DisplayAttempts(displayID any) int {
+	v, _ := p.display.get(displayID)
+	return v.Count
+}
@@ -0,0 +1,59 @@
+package password
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type dummyHash struct {
+	sync.Mutex
+	params string // algorithm and parameters the hash was made with
+	hash   string
+}
+
+// DummyHash returns a valid hash of a random password, which no password matches, made
+// with the configured algorithm and cost, so that comparing with it for an unknown id
+// takes as long as a genuine comparison and does not reveal that the id does not exist.
+// It is made once and remade when the algorithm or cost changes. It returns an empty
+// string if the hash cannot be made, e.g. because the cost is invalid.
+func (p *Passworder) DummyHash() string {
+	argon2, cost := p.argon2, 0
+	var params string
+	if argon2 != nil {
+		params = fmt.Sprintf("argon2id %+v", *argon2)
+	} else {
+		var err error
+		if cost, err = p.hashCost(); err != nil {
+			return ""
+		}
+		params = fmt.Sprint("bcrypt ", cost)
+	}
+	p.dummy.Lock()
+	defer p.dummy.Unlock()
+	if p.dummy.params == params {
+		return p.dummy.hash
+	}
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return ""
+	}
+	password := hex.EncodeToString(b)
+	var hash string
+	var err error
+	if argon2 != nil {
+		hash, err = HashArgon2(password, *argon2)
+	} else {
+		var hashed []byte
+		hashed, err = bcrypt.GenerateFromPassword([]byte(password), cost)
+		hash = string(hashed)
+	}
+	if err != nil {
+		return ""
+	}
+	p.dummy.params, p.dummy.hash = params, hash
+	return hash
+}
@@ -0,0 +1,32 @@
+package password
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Encoding converts binary data such as ciphertext to and from text.
+// *base64.Encoding satisfies this interface.
+type Encoding interface {
+	EncodeToString(src []byte) string
+	DecodeString(s string) ([]byte, error)
+}
+
+var (
+	_ Encoding = base64.StdEncoding
+	_ Encoding = hexEncoding{}
+)
+
+// Supported encodings.
+var (
+	StdEncoding    Encoding = base64.StdEncoding
+	URLEncoding    Encoding = base64.URLEncoding
+	RawStdEncoding Encoding = base64.RawStdEncoding
+	RawURLEncoding Encoding = base64.RawURLEncoding
+	HexEncoding    Encoding = hexEncoding{}
+)
+
+type hexEncoding struct{}
+
+func (hexEncoding) EncodeToString(src []byte) string      { return hex.EncodeToString(src) }
+func (hexEncoding) DecodeString(s string) ([]byte, error) { return hex.DecodeString(s) }
@@ -0,0 +1,94 @@
+package password
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncoding(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var password = "password"
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, []byte(password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, enc := range map[string]Encoding{
+		"std":    StdEncoding,
+		"url":    URLEncoding,
+		"rawstd": RawStdEncoding,
+		"rawurl": RawURLEncoding,
+		"hex":    HexEncoding,
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := enc.EncodeToString(ciphertext)
+			if b, err := enc.DecodeString(s); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(b, ciphertext) {
+				t.Fatal("round trip mismatch")
+			}
+			if s, err := DecryptPKCS1v15WithEncoding(priv, s, enc); err != nil {
+				t.Fatal(err)
+			} else if s != password {
+				t.Fatalf("expected password; got %s", s)
+			}
+			p := New(24*time.Hour, 5, priv)
+			p.SetEncoding(enc)
+			if err := p.Compare("", password, s); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestInvalidCiphertextEncoding(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DecryptPKCS1v15(priv, "not base64!")
+	if !errors.Is(err, ErrInvalidCiphertextEncoding) {
+		t.Errorf("expected ErrInvalidCiphertextEncoding; got %v", err)
+	}
+	var corrupt base64.CorruptInputError
+	if !errors.As(err, &corrupt) {
+		t.Errorf("expected base64.CorruptInputError in chain; got %v", err)
+	}
+	if _, err := DecryptPKCS1v15(priv, base64.StdEncoding.EncodeToString([]byte("garbage"))); err == nil || errors.Is(err, ErrInvalidCiphertextEncoding) {
+		t.Errorf("expected decryption error; got %v", err)
+	}
+}
+
+func TestLockOnDecryptError(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, priv)
+	p.SetLockOnDecryptError(false)
+	if err := p.Compare("id", "password", "not base64!"); !errors.Is(err, ErrInvalidCiphertextEncoding) {
+		t.Errorf("expected ErrInvalidCiphertextEncoding; got %v", err)
+	}
+	if _, ok := p.Attempts("id"); ok {
+		t.Error("expected no attempts recorded")
+	}
+	if err := p.Compare("id", "password", base64.StdEncoding.EncodeToString([]byte("garbage"))); err == nil || errors.Is(err, ErrInvalidCiphertextEncoding) {
+		t.Errorf("expected decryption error; got %v", err)
+	}
+	if !p.IsMaxAttempts("id") {
+		t.Error("expected a decryption failure to lock with lock disabled; got not")
+	}
+	p.Reset("id")
+	p.SetLockOnDecryptError(true)
+	p.Compare("id", "password", "not base64!")
+	if !p.IsMaxAttempts("id") {
+		t.Error("expected max attempts; got not")
+	}
+}
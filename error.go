@@ -3,30 +3,103 @@ package password
 import (
 	"errors"
 	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // ErrIncorrectPassword is returned when passwords are not equivalent.
 var ErrIncorrectPassword = errors.New("incorrect password")
 
-var _ error = incorrectPasswordError(0)
+var _ error = incorrectPasswordError{}
 
-type incorrectPasswordError int
+// incorrectPasswordError carries the failed attempts of the id and the mismatch
+// cause, e.g. bcrypt.ErrMismatchedHashAndPassword, if any.
+type incorrectPasswordError struct {
+	attempts int
+	err      error
+}
 
 func (incorrectPasswordError) Is(target error) bool { return target == ErrIncorrectPassword }
 
-func (i incorrectPasswordError) Error() string {
-	return fmt.Sprintf("incorrect password (%d)", i)
+func (e incorrectPasswordError) Unwrap() error { return e.err }
+
+func (e incorrectPasswordError) Error() string {
+	return fmt.Sprintf("incorrect password (%d)", e.attempts)
 }
 
+// ErrInvalidHash is returned when bcrypt cannot use a hash, e.g. one too short or
+// with an unknown version. The bcrypt error, such as bcrypt.ErrHashTooShort, is kept
+// in the chain.
+var ErrInvalidHash = errors.New("invalid hash")
+
+type invalidHashError struct{ err error }
+
+func (invalidHashError) Is(target error) bool { return target == ErrInvalidHash }
+
+func (e invalidHashError) Unwrap() error { return e.err }
+
+func (e invalidHashError) Error() string { return "invalid hash: " + e.err.Error() }
+
+// ErrAuthFailed is returned in opaque errors mode instead of ErrIncorrectPassword
+// and ErrMaxPasswordAttempts, so that a locked id cannot be told from a wrong password.
+var ErrAuthFailed = errors.New("authentication failed")
+
+// ErrInvalidID is returned when id is not comparable, e.g. a slice, which cannot key
+// the attempts, and in strict mode when id is nil or empty.
+var ErrInvalidID = errors.New("invalid id")
+
+// ErrCaptchaRequired is returned when id has reached the CAPTCHA threshold, see
+// SetCaptchaThreshold.
+var ErrCaptchaRequired = errors.New("captcha required")
+
+// ErrArgumentsSwapped is returned in strict mode when the password looks like a hash
+// but the hash does not, which usually means the arguments are passed in the wrong order.
+var ErrArgumentsSwapped = errors.New("hash and password arguments appear to be swapped")
+
+// ErrInvalidClientHash is returned in client hashed mode when the password is not a bcrypt hash.
+var ErrInvalidClientHash = errors.New("password is not a client-side bcrypt hash")
+
+// ErrNoPasswordSet is returned when the hash is empty, e.g. for an account created
+// through single sign-on, so that the user can be asked to set a password instead.
+var ErrNoPasswordSet = errors.New("no password set")
+
+// ErrPasswordTooLong is returned when a password exceeds the 72 bytes bcrypt uses.
+var ErrPasswordTooLong = bcrypt.ErrPasswordTooLong
+
+// ErrHashCostTooHigh is returned when a bcrypt hash has a cost above the one set by
+// SetMaxVerifyCost, or an argon2 hash has parameters too costly to verify safely.
+var ErrHashCostTooHigh = errors.New("hash cost too high")
+
+// ErrComparisonTimeout is returned when a hash comparison exceeds the configured timeout.
+var ErrComparisonTimeout = errors.New("password comparison timeout")
+
+// ErrInvalidCiphertextEncoding is returned when a ciphertext cannot be decoded with the
+// configured encoding, which points to a client bug rather than a wrong key.
+var ErrInvalidCiphertextEncoding = errors.New("invalid ciphertext encoding")
+
+// ErrInvalidPlaintext is returned when a decrypted password is not valid UTF-8, see SetRequireUTF8.
+var ErrInvalidPlaintext = errors.New("decrypted password is not valid UTF-8")
+
+// ErrTamperedPayload is returned when an encrypted password is unsigned or its HMAC
+// does not match, see SetClientHMACKey.
+var ErrTamperedPayload = errors.New("password payload signature mismatch")
+
 // ErrMaxPasswordAttempts is returned when exceeded maximum password attempts.
 var ErrMaxPasswordAttempts = errors.New("exceeded max password retry")
 
-var _ error = maxPasswordAttemptsError(0)
+var _ error = MaxAttemptsError{}
 
-type maxPasswordAttemptsError int
+// MaxAttemptsError is the error returned by comparisons when the id exceeded maximum
+// password attempts, it matches ErrMaxPasswordAttempts with errors.Is and carries the
+// remaining lockout as of the comparison, e.g. for a Retry-After header.
+type MaxAttemptsError struct {
+	Max        int
+	RetryAfter time.Duration
+}
 
-func (maxPasswordAttemptsError) Is(target error) bool { return target == ErrMaxPasswordAttempts }
+func (MaxAttemptsError) Is(target error) bool { return target == ErrMaxPasswordAttempts }
 
-func (i maxPasswordAttemptsError) Error() string {
-	return fmt.Sprintf("exceeded maximum password attempts (%d)", i)
+func (e MaxAttemptsError) Error() string {
+	return fmt.Sprintf("exceeded maximum password attempts (%d)", e.Max)
 }
@@ -0,0 +1,76 @@
+package password
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrPasswordExpired is returned by CompareExpiring when the password matches but
+// the hash has expired.
+var ErrPasswordExpired = errors.New("password expired")
+
+// ErrInvalidExpiringHash is returned when a hash is not made by HashPasswordExpiring.
+var ErrInvalidExpiringHash = errors.New("invalid expiring hash")
+
+const expiringPrefix = "$exp$"
+
+// bindExpiry binds password to expires, so that a hash of the result only verifies
+// with the same expiry.
+func bindExpiry(expires time.Time, password string) string {
+	return applyPepper([]byte("expires "+strconv.FormatInt(expires.Unix(), 10)), password)
+}
+
+// HashPasswordExpiring is like HashPassword, but the hash expires after ttl, e.g. for a
+// temporary password set by an administrator, see CompareExpiring. The expiry is
+// prefixed in clear, as in $exp$<unix seconds>$2a$..., and the password is hashed
+// bound to it, so that a hash whose expiry has been altered never verifies.
+func (p *Passworder) HashPasswordExpiring(password string, ttl time.Duration) (string, error) {
+	if err := p.policy.Validate(password); err != nil {
+		return "", err
+	}
+	expires := p.now().Add(ttl).Truncate(time.Second)
+	hash, err := p.hash(bindExpiry(expires, p.normalize(password)))
+	if err != nil {
+		return "", err
+	}
+	return expiringPrefix + strconv.FormatInt(expires.Unix(), 10) + string(hash), nil
+}
+
+func parseExpiring(hash string) (expires time.Time, inner string, err error) {
+	if hash == "" {
+		return time.Time{}, "", ErrNoPasswordSet
+	}
+	s, ok := strings.CutPrefix(hash, expiringPrefix)
+	if !ok {
+		return time.Time{}, "", ErrInvalidExpiringHash
+	}
+	i := strings.IndexByte(s, '$')
+	if i <= 0 {
+		return time.Time{}, "", ErrInvalidExpiringHash
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidExpiringHash
+	}
+	return time.Unix(n, 0), s[i:], nil
+}
+
+// CompareExpiring is like CompareHashAndPassword for a hash made by HashPasswordExpiring.
+// It returns ErrPasswordExpired if the password matches but the hash has expired, so
+// that the user can be made to choose a new one; a wrong password is reported as
+// such whether or not the hash has expired.
+func (p *Passworder) CompareExpiring(id any, hash, password string) error {
+	expires, inner, err := parseExpiring(hash)
+	if err != nil {
+		return err
+	}
+	if _, err := p.compare(id, inner, password, compareOptions{hash: true, expires: expires}); err != nil {
+		return err
+	}
+	if !p.now().Before(expires) {
+		return ErrPasswordExpired
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package password
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCompareExpiring(t *testing.T) {
+	now := time.Now()
+	p := New(24*time.Hour, 5, nil)
+	p.now = func() time.Time { return now }
+	hash, err := p.HashPasswordExpiring("password", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareExpiring("id", hash, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Hash(hash).Value(); err != nil || v != hash {
+		t.Errorf("expected expiring hash stored as is; got %v, %v", v, err)
+	}
+	if alg, err := Algorithm(hash); err != nil || alg != "bcrypt" || !IsValidHash(hash) {
+		t.Errorf("expected valid bcrypt hash; got %q, %v", alg, err)
+	}
+	if err := p.CompareExpiring("id", hash, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+
+	expires, inner, err := parseExpiring(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extended := expiringPrefix + strconv.FormatInt(expires.Add(24*time.Hour).Unix(), 10) + inner
+	if err := p.CompareExpiring("other", extended, "password"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected tampered expiry rejected; got %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	p.Reset("id")
+	if err := p.CompareExpiring("id", hash, "password"); err != ErrPasswordExpired {
+		t.Errorf("expected ErrPasswordExpired; got %v", err)
+	}
+	if err := p.CompareExpiring("id", hash, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if err := p.CompareExpiring("id", "$2a$04$abc", "password"); err != ErrInvalidExpiringHash {
+		t.Errorf("expected ErrInvalidExpiringHash; got %v", err)
+	}
+}
@@ -6,3 +6,5 @@ require (
 	github.com/sunshineplan/utils v0.1.74
 	golang.org/x/crypto v0.32.0
 )
+
+require golang.org/x/sys v0.29.0 // indirect
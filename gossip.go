@@ -0,0 +1,174 @@
+package password
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SyncUpdate is the failed attempts count of an id on an instance, exchanged
+// between instances which share lockouts, see SetSync.
+type SyncUpdate struct {
+	Node    string
+	ID      any
+	Count   int
+	Expires time.Time
+
+	// Reset asks the receivers to clear their own count of ID as well, after a
+	// successful comparison or Reset on Node.
+	Reset bool
+}
+
+// SyncTransport delivers updates to the other instances, e.g. over UDP or a message
+// bus, which pass them to ApplyUpdate. Delivery may be lossy and out of order.
+type SyncTransport interface {
+	Broadcast(u SyncUpdate) error
+}
+
+// peers is the last known counts of the other instances.
+type peers struct {
+	mu        sync.Mutex
+	node      string
+	transport SyncTransport
+	counts    map[any]map[string]SyncUpdate
+}
+
+// SetSync shares lockouts with other instances without a common store: every change
+// of a local count is broadcast asynchronously through t under node, which must be
+// unique among the instances, and IsMaxAttempts adds the known counts of the other
+// instances to the local one. A success or Reset clears the id on all instances.
+// Counts are eventually consistent, an id can exceed the
+// maximum attempts by as many failures as are in flight, and the lock hook only fires
+// for local crossings. Call SyncAll periodically to recover from lost updates.
+// nil t disables it.
+func (l *AttemptLimiter) SetSync(node string, t SyncTransport) {
+	if t == nil {
+		l.peers = nil
+		return
+	}
+	l.peers = &peers{node: node, transport: t, counts: make(map[any]map[string]SyncUpdate)}
+}
+
+// ApplyUpdate records the count of another instance received from the transport.
+func (l *AttemptLimiter) ApplyUpdate(u SyncUpdate) {
+	p := l.peers
+	if p == nil || u.Node == p.node || !validID(u.ID) {
+		return
+	}
+	if u.Reset {
+		p.mu.Lock()
+		delete(p.counts, u.ID)
+		p.mu.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.reset(u.ID)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if u.Count <= 0 || !l.now().Before(u.Expires) {
+		if m := p.counts[u.ID]; m != nil {
+			if delete(m, u.Node); len(m) == 0 {
+				delete(p.counts, u.ID)
+			}
+		}
+		return
+	}
+	m := p.counts[u.ID]
+	if m == nil {
+		m = make(map[string]SyncUpdate)
+		p.counts[u.ID] = m
+	}
+	m[u.Node] = u
+}
+
+// SyncAll broadcasts the counts of all local records.
+func (l *AttemptLimiter) SyncAll() {
+	if l.peers == nil {
+		return
+	}
+	for id, v := range l.all() {
+		l.broadcast(id, v.Count, v.Expires)
+	}
+}
+
+func (l *AttemptLimiter) broadcast(id any, count int, expires time.Time) {
+	p := l.peers
+	if p == nil {
+		return
+	}
+	l.send(id, SyncUpdate{Node: p.node, ID: id, Count: count, Expires: expires})
+}
+
+func (l *AttemptLimiter) send(id any, u SyncUpdate) {
+	p := l.peers
+	go func() {
+		if err := p.transport.Broadcast(u); err != nil {
+			l.log(slog.LevelDebug, "failed to broadcast attempts", id, slog.String("error", err.Error()))
+		}
+	}()
+}
+
+// remoteCount returns the sum of the unexpired counts of id on other instances.
+func (l *AttemptLimiter) remoteCount(id any) (n int) {
+	for _, u := range l.remoteUpdates(id) {
+		n += u.Count
+	}
+	return
+}
+
+// remoteUpdates returns the unexpired counts of id on other instances.
+func (l *AttemptLimiter) remoteUpdates(id any) (updates []SyncUpdate) {
+	p := l.peers
+	if p == nil || !validID(id) {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := l.now()
+	for node, u := range p.counts[id] {
+		if now.Before(u.Expires) {
+			updates = append(updates, u)
+		} else {
+			delete(p.counts[id], node)
+		}
+	}
+	return
+}
+
+// remoteRetryAfter returns how long until the counts of id, local v and remote ones,
+// expire below max, as each instance's count expires as a whole.
+func remoteRetryAfter(v Record, remote []SyncUpdate, now time.Time, max int) time.Duration {
+	if v.Count > 0 {
+		remote = append(remote, SyncUpdate{Count: v.Count, Expires: v.Expires})
+	}
+	slices.SortFunc(remote, func(a, b SyncUpdate) int { return a.Expires.Compare(b.Expires) })
+	n := 0
+	for _, u := range remote {
+		n += u.Count
+	}
+	for _, u := range remote {
+		if n -= u.Count; n < max {
+			return u.Expires.Sub(now)
+		}
+	}
+	return 0
+}
+
+// forget drops the known counts of id on other instances, and tells them
+// to drop the local one.
+func (l *AttemptLimiter) forget(id any) {
+	p := l.peers
+	if p == nil || !validID(id) {
+		return
+	}
+	p.mu.Lock()
+	delete(p.counts, id)
+	p.mu.Unlock()
+	l.broadcastReset(id)
+}
+
+func (l *AttemptLimiter) broadcastReset(id any) {
+	l.send(id, SyncUpdate{Node: l.peers.node, ID: id, Reset: true})
+}
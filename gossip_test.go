@@ -0,0 +1,64 @@
+package password
+
+import (
+	"testing"
+	"time"
+)
+
+type chanTransport chan SyncUpdate
+
+func (c chanTransport) Broadcast(u SyncUpdate) error {
+	c <- u
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	a, b := NewAttemptLimiter(time.Minute, 3), NewAttemptLimiter(time.Minute, 3)
+	ca, cb := make(chanTransport, 10), make(chanTransport, 10)
+	a.SetSync("a", ca)
+	b.SetSync("b", cb)
+	deliver := func(c chanTransport, to *AttemptLimiter) {
+		select {
+		case u := <-c:
+			to.ApplyUpdate(u)
+		case <-time.After(time.Second):
+			t.Fatal("expected an update; got none")
+		}
+	}
+
+	a.Fail("id")
+	deliver(ca, b)
+	a.Fail("id")
+	deliver(ca, b)
+	if b.Exceeded("id") {
+		t.Fatal("expected not exceeded; got exceeded")
+	}
+	if !b.WouldLockNext("id") {
+		t.Error("expected would lock with remote attempts; got not")
+	}
+	b.Fail("id")
+	deliver(cb, a)
+	if !a.Exceeded("id") || !b.Exceeded("id") {
+		t.Errorf("expected exceeded on both; got %v %v", a.Exceeded("id"), b.Exceeded("id"))
+	}
+
+	if s := a.Status("id"); !s.Locked || s.Attempts != 3 || s.RetryAfter <= 0 {
+		t.Errorf("expected locked by remote attempts; got %+v", s)
+	}
+
+	a.Succeed("id")
+	deliver(ca, b)
+	if a.Exceeded("id") || b.Exceeded("id") {
+		t.Errorf("expected not exceeded on both; got %v %v", a.Exceeded("id"), b.Exceeded("id"))
+	}
+	if _, ok := b.Attempts("id"); ok {
+		t.Error("expected the local count of b cleared by the success on a")
+	}
+}
+
+func TestMaxAttemptsZero(t *testing.T) {
+	p := New(time.Minute, 0, nil)
+	if err := p.Compare("x", "a", "a"); err != nil {
+		t.Errorf("expected unknown id not locked; got %v", err)
+	}
+}
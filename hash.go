@@ -0,0 +1,288 @@
+package password
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownAlgorithm is returned when the algorithm of a hash cannot be recognized.
+var ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+
+var algorithmPrefixes = []struct{ prefix, algorithm string }{
+	{"$2$", "bcrypt"},
+	{"$2a$", "bcrypt"},
+	{"$2b$", "bcrypt"},
+	{"$2x$", "bcrypt"},
+	{"$2y$", "bcrypt"},
+	{"$argon2id$", "argon2id"},
+	{"$argon2i$", "argon2i"},
+	{"$argon2d$", "argon2d"},
+	{"$scrypt$", "scrypt"},
+	{"$7$", "scrypt"},
+	{"$pbkdf2", "pbkdf2"},
+}
+
+// Algorithm returns the algorithm of a stored hash by its prefix, such as
+// "bcrypt", "argon2id", "argon2i", "scrypt" or "pbkdf2". It does not mean the
+// hash can be verified, see CompareHashAndPassword for supported algorithms.
+// A hash tagged by HashVersioned or made by HashPasswordExpiring is recognized by
+// the hash it wraps.
+func Algorithm(hash string) (string, error) {
+	hash = innerHash(hash)
+	for _, i := range algorithmPrefixes {
+		if strings.HasPrefix(hash, i.prefix) {
+			return i.algorithm, nil
+		}
+	}
+	return "", ErrUnknownAlgorithm
+}
+
+// innerHash returns hash without the version tag of HashVersioned and the expiry
+// prefix of HashPasswordExpiring.
+func innerHash(hash string) string {
+	if _, untagged, err := ParseVersioned(hash); err == nil {
+		hash = untagged
+	}
+	if strings.HasPrefix(hash, expiringPrefix) {
+		if _, inner, err := parseExpiring(hash); err == nil {
+			hash = inner
+		}
+	}
+	return hash
+}
+
+var (
+	_ sql.Scanner   = new(Hash)
+	_ driver.Valuer = Hash("")
+)
+
+// Hash is a stored password hash to be kept in a single database column. The string
+// form (modular crypt or PHC format) carries the algorithm, parameters, salt and digest,
+// so it can be passed to CompareHashAndPassword as string(h). An empty Hash is stored as NULL.
+type Hash string
+
+// Algorithm returns the algorithm of the hash.
+func (h Hash) Algorithm() (string, error) { return Algorithm(string(h)) }
+
+// Scan implements sql.Scanner.
+func (h *Hash) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*h = ""
+	case string:
+		*h = Hash(v)
+	case []byte:
+		*h = Hash(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Hash", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (h Hash) Value() (driver.Value, error) {
+	if h == "" {
+		return nil, nil
+	}
+	if _, err := h.Algorithm(); err != nil {
+		return nil, err
+	}
+	return string(h), nil
+}
+
+// verifiers is the hash comparison of each supported algorithm.
+var verifiers = map[string]func(hash, password string) error{
+	"bcrypt":   compareBcrypt,
+	"argon2id": compareArgon2,
+	"argon2i":  compareArgon2,
+}
+
+// SupportedAlgorithms returns the sorted algorithms CompareHashAndPassword can verify,
+// as named by Algorithm.
+func SupportedAlgorithms() []string {
+	return slices.Sorted(maps.Keys(verifiers))
+}
+
+func compareBcrypt(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil && err != bcrypt.ErrMismatchedHashAndPassword {
+		return invalidHashError{err}
+	}
+	return err
+}
+
+// compareHash compares a bcrypt or argon2 hash with its possible plaintext equivalent.
+func compareHash(hash, password string) error {
+	alg, err := Algorithm(hash)
+	if err != nil {
+		// Unrecognized hashes are left to bcrypt for its detailed errors.
+		return compareBcrypt(hash, password)
+	}
+	if verify, ok := verifiers[alg]; ok {
+		return verify(hash, password)
+	}
+	return fmt.Errorf("unsupported hash algorithm %s", alg)
+}
+
+// DefaultBcryptPrefixes is the bcrypt version prefixes accepted by comparisons by default.
+// It leaves out "$2x$", see SetAcceptedPrefixes.
+var DefaultBcryptPrefixes = []string{"$2$", "$2a$", "$2b$", "$2y$"}
+
+// SetAcceptedPrefixes sets the bcrypt version prefixes accepted by hash comparisons,
+// nil restores DefaultBcryptPrefixes. Hashes with an accepted prefix are verified as
+// $2a$ hashes, which is also how the other versions compute, so a newly introduced
+// or foreign prefix can be allowed without waiting for bcrypt to know it. Hashes
+// with another bcrypt prefix are rejected with ErrUnknownAlgorithm, such as "$2x$"
+// by default, which marks hashes of the crypt_blowfish sign extension bug. They can
+// be accepted with append(DefaultBcryptPrefixes, "$2x$").
+func (p *Passworder) SetAcceptedPrefixes(prefixes []string) {
+	p.prefixes = slices.Clone(prefixes)
+}
+
+// acceptPrefix returns hash with its bcrypt prefix normalized to $2a$,
+// hashes of other algorithms are returned unchanged.
+func (p *Passworder) acceptPrefix(hash string) (string, error) {
+	if len(hash) < 4 || hash[:2] != "$2" {
+		return hash, nil
+	}
+	prefix := hash[:3]
+	if hash[2] != '$' {
+		prefix = hash[:4]
+	}
+	prefixes := p.prefixes
+	if prefixes == nil {
+		prefixes = DefaultBcryptPrefixes
+	}
+	if !slices.Contains(prefixes, prefix) {
+		return "", fmt.Errorf("%w: bcrypt prefix %q not accepted", ErrUnknownAlgorithm, prefix)
+	}
+	return "$2a$" + hash[len(prefix):], nil
+}
+
+// HashesEqual reports whether two stored hash strings are identical, in constant time,
+// e.g. to decide whether an updated hash needs to be written to the database. It does
+// not verify a password: two hashes of the same password differ by their salt, so use
+// CompareHashAndPassword for that.
+func HashesEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// CompareHex compares two hex-encoded digests, of either case, in constant time and
+// returns ErrIncorrectPassword if they differ. Both are decoded to bytes first, which
+// normalizes the case, so that the constant-time comparison is of the digests
+// themselves. It is meant for legacy digests such as unsalted SHA-256, and can be
+// passed to CompareWith as the verifier of such hashes.
+func CompareHex(storedHex, computedHex string) error {
+	stored, err := hex.DecodeString(storedHex)
+	if err != nil {
+		return err
+	}
+	computed, err := hex.DecodeString(computedHex)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(stored, computed) != 1 {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+const bcryptAlphabet = "./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// IsValidHash reports whether hash is a well-formed bcrypt or argon2 hash, checking
+// its prefix, version, parameters and encoded body length without any comparison.
+// A hash tagged by HashVersioned or made by HashPasswordExpiring is checked by the
+// hash it wraps.
+func IsValidHash(hash string) bool {
+	hash = innerHash(hash)
+	switch alg, _ := Algorithm(hash); alg {
+	case "bcrypt":
+		// $2a$10$ followed by 22 characters of salt and 31 of digest.
+		// The original $2$ prefix lacks the minor version.
+		if strings.HasPrefix(hash, "$2$") {
+			hash = "$2a$" + hash[3:]
+		}
+		if len(hash) != 60 || hash[6] != '$' {
+			return false
+		}
+		cost, err := strconv.Atoi(hash[4:6])
+		if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return false
+		}
+		for _, c := range hash[7:] {
+			if !strings.ContainsRune(bcryptAlphabet, c) {
+				return false
+			}
+		}
+		return true
+	case "argon2id", "argon2i":
+		_, err := parseArgon2(hash)
+		return err == nil
+	}
+	return false
+}
+
+// IsUpToDate reports whether hash uses the algorithm and parameters HashPassword
+// currently produces: bcrypt at the configured cost, or at least the calibrated one
+// with SetAdaptiveCost, or argon2id with the configured parameters. It returns an error if hash is not a recognized bcrypt or argon2 hash.
+func (p *Passworder) IsUpToDate(hash string) (bool, error) {
+	hash = innerHash(hash)
+	alg, err := Algorithm(hash)
+	if err != nil {
+		return false, err
+	}
+	switch alg {
+	case "bcrypt":
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, err
+		}
+		return p.argon2 == nil && p.costUpToDate(cost), nil
+	case "argon2id", "argon2i":
+		h, err := parseArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		return p.argon2 != nil && h.variant == "argon2id" && h.params == *p.argon2, nil
+	}
+	return false, fmt.Errorf("unsupported hash algorithm %s", alg)
+}
+
+// PlanRehash returns the bcrypt hashes whose cost is lower than targetCost,
+// so that they can be flagged and rehashed at next successful login.
+// Hashes that are not bcrypt hashes are ignored.
+func PlanRehash(hashes []string, targetCost int) []string {
+	var res []string
+	for _, hash := range hashes {
+		if cost, err := bcrypt.Cost([]byte(hash)); err == nil && cost < targetCost {
+			res = append(res, hash)
+		}
+	}
+	return res
+}
+
+// CalibrateCost returns the lowest bcrypt cost whose hashing takes at least target
+// on the current machine, or bcrypt.MaxCost if none does.
+func CalibrateCost(target time.Duration) (int, error) {
+	for cost := bcrypt.MinCost; cost < bcrypt.MaxCost; cost++ {
+		start := time.Now()
+		if _, err := bcrypt.GenerateFromPassword([]byte("calibration password"), cost); err != nil {
+			return 0, err
+		}
+		if time.Since(start) >= target {
+			return cost, nil
+		}
+	}
+	return bcrypt.MaxCost, nil
+}
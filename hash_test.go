@@ -0,0 +1,317 @@
+package password
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPlanRehash(t *testing.T) {
+	var hashes []string
+	for _, cost := range []int{bcrypt.MinCost, 6, 8} {
+		b, err := bcrypt.GenerateFromPassword([]byte("password"), cost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, string(b))
+	}
+	hashes = append(hashes, "bad hash")
+	if res := PlanRehash(hashes, 6); !reflect.DeepEqual(res, hashes[:1]) {
+		t.Errorf("expected %v; got %v", hashes[:1], res)
+	}
+	if res := PlanRehash(hashes, 10); !reflect.DeepEqual(res, hashes[:3]) {
+		t.Errorf("expected %v; got %v", hashes[:3], res)
+	}
+	if res := PlanRehash(hashes, bcrypt.MinCost); res != nil {
+		t.Errorf("expected nil; got %v", res)
+	}
+}
+
+func TestAlgorithm(t *testing.T) {
+	bcryptHash, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon2Hash, err := HashArgon2("password", Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hash, expected := range map[string]string{
+		bcryptHash: "bcrypt",
+		"$2y$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a": "bcrypt",
+		"$2$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a":  "bcrypt",
+		argon2Hash: "argon2id",
+		"$argon2i$v=19$m=65536,t=2,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG":               "argon2i",
+		"$scrypt$ln=16,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E": "scrypt",
+		"$pbkdf2-sha256$29000$N2bMWev9H8N4r5GyFoKQEg$dnB8SiBOGdqQEA7fdw/IGQYFqLjX0gG8r8RyiO2QZk4":  "pbkdf2",
+	} {
+		if alg, err := Algorithm(hash); err != nil {
+			t.Errorf("%s: %v", hash, err)
+		} else if alg != expected {
+			t.Errorf("%s: expected %s; got %s", hash, expected, alg)
+		}
+	}
+	for _, hash := range []string{"", "password", "$1$salt$hash"} {
+		if _, err := Algorithm(hash); err != ErrUnknownAlgorithm {
+			t.Errorf("%q: expected ErrUnknownAlgorithm; got %v", hash, err)
+		}
+	}
+}
+
+func TestCalibrateCost(t *testing.T) {
+	if cost, err := CalibrateCost(0); err != nil {
+		t.Fatal(err)
+	} else if cost != bcrypt.MinCost {
+		t.Errorf("expected %d; got %d", bcrypt.MinCost, cost)
+	}
+	cost, err := CalibrateCost(5 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, nil)
+	p.SetCost(cost)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := bcrypt.Cost([]byte(hash)); err != nil {
+		t.Fatal(err)
+	} else if c != cost {
+		t.Errorf("expected %d; got %d", cost, c)
+	}
+}
+
+func TestHashScanValue(t *testing.T) {
+	hashed, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []any{hashed, []byte(hashed)} {
+		var h Hash
+		if err := h.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if alg, err := h.Algorithm(); err != nil {
+			t.Fatal(err)
+		} else if alg != "bcrypt" {
+			t.Errorf("expected bcrypt; got %s", alg)
+		}
+		if v, err := h.Value(); err != nil {
+			t.Fatal(err)
+		} else if v != hashed {
+			t.Errorf("expected %s; got %v", hashed, v)
+		}
+		if err := CompareHashAndPassword("", string(h), "password"); err != nil {
+			t.Error(err)
+		}
+	}
+	var h Hash
+	if err := h.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Value(); err != nil || v != nil {
+		t.Errorf("expected nil, nil; got %v, %v", v, err)
+	}
+	if _, err := Hash("bad hash").Value(); err != ErrUnknownAlgorithm {
+		t.Errorf("expected ErrUnknownAlgorithm; got %v", err)
+	}
+	if err := h.Scan(1); err == nil {
+		t.Error("expected non-nil err; got nil")
+	}
+}
+
+func TestIsValidHash(t *testing.T) {
+	bcryptHash, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hash, expected := range map[string]bool{
+		bcryptHash: true,
+		"$2y$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a":                             true,
+		"$2y$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1":                              false,
+		"$2$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a":                              true,
+		"$2$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1":                               false,
+		"$2y$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1+":                             false,
+		"$2y$03$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a":                             false,
+		"$2y$1a$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a":                             false,
+		"$3a$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a":                             false,
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc":   true,
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ":                                               false,
+		"$scrypt$ln=16,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E": false,
+		"": false,
+	} {
+		if ok := IsValidHash(hash); ok != expected {
+			t.Errorf("%q: expected %v; got %v", hash, expected, ok)
+		}
+	}
+}
+
+func TestAcceptedPrefixes(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"$2$", "$2b$", "$2y$"} {
+		if err := p.CompareHashAndPassword("id", prefix+string(hash[4:]), "password"); err != nil {
+			t.Errorf("%s: %v", prefix, err)
+		}
+	}
+	if err := p.CompareHashAndPassword("id", "$2x$"+string(hash[4:]), "password"); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("expected ErrUnknownAlgorithm by default; got %v", err)
+	}
+	p.SetAcceptedPrefixes(append(DefaultBcryptPrefixes, "$2x$"))
+	if err := p.CompareHashAndPassword("id", "$2x$"+string(hash[4:]), "password"); err != nil {
+		t.Error(err)
+	}
+	p.SetAcceptedPrefixes([]string{"$2a$", "$2b$"})
+	if err := p.CompareHashAndPassword("id", "$2y$"+string(hash[4:]), "password"); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("expected ErrUnknownAlgorithm; got %v", err)
+	}
+	if err := p.CompareHashAndPassword("id", "$2b$"+string(hash[4:]), "password"); err != nil {
+		t.Error(err)
+	}
+	p.SetAcceptedPrefixes([]string{"$2c$"})
+	if err := p.CompareHashAndPassword("id", "$2c$"+string(hash[4:]), "password"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIsUpToDate(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetCost(5)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := p.IsUpToDate(hash); err != nil || !ok {
+		t.Errorf("expected up to date; got %v, %v", ok, err)
+	}
+	p.SetCost(6)
+	if ok, _ := p.IsUpToDate(hash); ok {
+		t.Error("expected outdated cost; got up to date")
+	}
+	params := Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+	p.SetArgon2Params(&params)
+	if ok, _ := p.IsUpToDate(hash); ok {
+		t.Error("expected outdated algorithm; got up to date")
+	}
+	hash, err = p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := p.IsUpToDate(hash); err != nil || !ok {
+		t.Errorf("expected up to date; got %v, %v", ok, err)
+	}
+	p.SetArgon2Params(&Argon2Params{Memory: 2048, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+	if ok, _ := p.IsUpToDate(hash); ok {
+		t.Error("expected outdated parameters; got up to date")
+	}
+	if _, err := p.IsUpToDate("5f4dcc3b5aa765d61d8327deb882cf99"); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("expected ErrUnknownAlgorithm; got %v", err)
+	}
+}
+
+func TestSupportedAlgorithms(t *testing.T) {
+	if algs := SupportedAlgorithms(); !reflect.DeepEqual(algs, []string{"argon2i", "argon2id", "bcrypt"}) {
+		t.Errorf("unexpected algorithms: %v", algs)
+	}
+}
+
+func TestCompareHex(t *testing.T) {
+	const digest = "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8"
+	if err := CompareHex(digest, strings.ToUpper(digest)); err != nil {
+		t.Error(err)
+	}
+	if err := CompareHex(strings.ToUpper(digest[:32])+digest[32:], digest); err != nil {
+		t.Errorf("expected mixed case stored digest to match; got %v", err)
+	}
+	if err := CompareHex(digest, digest[:62]+"00"); err != ErrIncorrectPassword {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if err := CompareHex(digest, "zz"); err == nil {
+		t.Error("expected decoding error; got nil")
+	}
+	p := New(24*time.Hour, 5, nil)
+	if err := p.CompareWith("id", digest, digest[:62]+"00", CompareHex); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if v, _ := p.Attempts("id"); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
+	}
+}
+
+func TestHashesEqual(t *testing.T) {
+	a, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !HashesEqual(a, a) {
+		t.Error("expected equal hashes")
+	}
+	if HashesEqual(a, b) {
+		t.Error("expected hashes with different salts to differ")
+	}
+	if HashesEqual(a, a[:len(a)-1]) {
+		t.Error("expected hashes of different lengths to differ")
+	}
+}
+
+func TestDummyHash(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	hash := p.DummyHash()
+	if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost != bcrypt.MinCost {
+		t.Fatalf("expected bcrypt hash of cost %d; got %d, %v", bcrypt.MinCost, cost, err)
+	}
+	if p.DummyHash() != hash {
+		t.Error("expected cached dummy hash")
+	}
+	if err := p.CompareHashAndPassword("id", hash, ""); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	p.SetCost(bcrypt.MinCost + 1)
+	if cost, _ := bcrypt.Cost([]byte(p.DummyHash())); cost != bcrypt.MinCost+1 {
+		t.Errorf("expected dummy hash remade with cost %d; got %d", bcrypt.MinCost+1, cost)
+	}
+	p.SetArgon2Params(&Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+	if alg, _ := Algorithm(p.DummyHash()); alg != "argon2id" {
+		t.Errorf("expected argon2id dummy hash; got %s", alg)
+	}
+}
+
+func TestHashPasswordDeterministic(t *testing.T) {
+	// Test vector of OpenBSD bcrypt.
+	const expected = "$2a$06$DCq7YPn5Rq63x1Lad4cll.TV4S6ytwfsfvkgY8jIucDrjc8deX1s."
+	salt, err := bcryptEncoding.DecodeString("DCq7YPn5Rq63x1Lad4cll.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := HashPasswordDeterministic("", salt, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != expected {
+		t.Errorf("expected %s; got %s", expected, hash)
+	}
+	hash, err = HashPasswordDeterministic("password", salt, bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := HashPasswordDeterministic("password", salt, bcrypt.MinCost); again != hash {
+		t.Error("expected the same hash")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("password")); err != nil {
+		t.Errorf("expected hash verified by bcrypt; got %v", err)
+	}
+	if _, err := HashPasswordDeterministic("password", salt[:8], bcrypt.MinCost); err == nil {
+		t.Error("expected short salt rejected")
+	}
+}
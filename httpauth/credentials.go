@@ -0,0 +1,87 @@
+// Package httpauth provides HTTP helpers for the login workflow of package password.
+package httpauth
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+)
+
+// ErrMissingCredentials is returned when the id or password field is absent.
+var ErrMissingCredentials = errors.New("missing credentials")
+
+// DefaultMaxBodySize is the request body size limit used when Decoder.MaxBodySize is zero.
+const DefaultMaxBodySize = 1 << 20
+
+// Decoder decodes login credentials from form values or a JSON object body.
+type Decoder struct {
+	IDField       string // defaults to "username"
+	PasswordField string // defaults to "password"
+	MaxBodySize   int64  // defaults to DefaultMaxBodySize
+}
+
+// DefaultDecoder is the Decoder used by DecodeCredentials.
+var DefaultDecoder = new(Decoder)
+
+// DecodeCredentials decodes credentials from r with DefaultDecoder.
+func DecodeCredentials(r *http.Request) (id, password string, err error) {
+	return DefaultDecoder.Decode(r)
+}
+
+func (d *Decoder) fields() (string, string) {
+	id, password := d.IDField, d.PasswordField
+	if id == "" {
+		id = "username"
+	}
+	if password == "" {
+		password = "password"
+	}
+	return id, password
+}
+
+// Decode decodes credentials from r. A JSON object is read when the content type
+// is application/json, otherwise form values are read. The body is limited to
+// MaxBodySize bytes.
+func (d *Decoder) Decode(r *http.Request) (id, password string, err error) {
+	idField, passwordField := d.fields()
+	size := d.MaxBodySize
+	if size <= 0 {
+		size = DefaultMaxBodySize
+	}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, size)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var m map[string]json.RawMessage
+		if err = json.NewDecoder(r.Body).Decode(&m); err != nil {
+			return
+		}
+		var ok bool
+		if id, ok = jsonString(m[idField]); !ok {
+			return "", "", ErrMissingCredentials
+		}
+		if password, ok = jsonString(m[passwordField]); !ok {
+			return "", "", ErrMissingCredentials
+		}
+	} else {
+		if err = r.ParseForm(); err != nil {
+			return
+		}
+		if !r.Form.Has(idField) || !r.Form.Has(passwordField) {
+			return "", "", ErrMissingCredentials
+		}
+		id, password = r.Form.Get(idField), r.Form.Get(passwordField)
+	}
+	return
+}
+
+func jsonString(b json.RawMessage) (s string, ok bool) {
+	if b == nil {
+		return "", false
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
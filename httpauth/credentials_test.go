@@ -0,0 +1,39 @@
+package httpauth
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCredentials(t *testing.T) {
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(url.Values{"username": {"user"}, "password": {"pass"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id, password, err := DecodeCredentials(r); err != nil {
+		t.Fatal(err)
+	} else if id != "user" || password != "pass" {
+		t.Errorf("expected user pass; got %s %s", id, password)
+	}
+
+	r = httptest.NewRequest("POST", "/login", strings.NewReader(`{"email":"user@example.com","secret":"pass"}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	d := &Decoder{IDField: "email", PasswordField: "secret"}
+	if id, password, err := d.Decode(r); err != nil {
+		t.Fatal(err)
+	} else if id != "user@example.com" || password != "pass" {
+		t.Errorf("expected user@example.com pass; got %s %s", id, password)
+	}
+
+	r = httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"user"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if _, _, err := DecodeCredentials(r); err != ErrMissingCredentials {
+		t.Errorf("expected ErrMissingCredentials; got %v", err)
+	}
+
+	r = httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"user","password":"`+strings.Repeat("a", 100)+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if _, _, err := (&Decoder{MaxBodySize: 64}).Decode(r); err == nil {
+		t.Error("expected non-nil err; got nil")
+	}
+}
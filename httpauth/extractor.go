@@ -0,0 +1,60 @@
+package httpauth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IDExtractor extracts the id used to count password attempts from a request.
+type IDExtractor interface {
+	Extract(r *http.Request) any
+}
+
+// IDExtractorFunc is an adapter to allow the use of ordinary functions as IDExtractor.
+type IDExtractorFunc func(r *http.Request) any
+
+// Extract calls f(r).
+func (f IDExtractorFunc) Extract(r *http.Request) any { return f(r) }
+
+// RemoteIP extracts the IP address of the peer.
+var RemoteIP IDExtractor = IDExtractorFunc(remoteIP)
+
+func remoteIP(r *http.Request) any {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ForwardedFor extracts the first hop of the X-Forwarded-For header, falling back to
+// RemoteIP. The header is set by clients as they like, so only use it behind a proxy
+// which overwrites it.
+var ForwardedFor IDExtractor = IDExtractorFunc(func(r *http.Request) any {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		if first, _, _ := strings.Cut(xff, ","); strings.TrimSpace(first) != "" {
+			return strings.TrimSpace(first)
+		}
+	}
+	return remoteIP(r)
+})
+
+// FormField extracts the form value of name, such as the username.
+func FormField(name string) IDExtractor {
+	return IDExtractorFunc(func(r *http.Request) any { return r.FormValue(name) })
+}
+
+// Compose returns an IDExtractor whose id combines the ids of all extractors,
+// e.g. Compose(RemoteIP, FormField("username")) counts attempts per user per IP.
+// The combined id is a string.
+func Compose(extractors ...IDExtractor) IDExtractor {
+	return IDExtractorFunc(func(r *http.Request) any {
+		ids := make([]string, len(extractors))
+		for i, e := range extractors {
+			ids[i] = fmt.Sprint(e.Extract(r))
+		}
+		return strings.Join(ids, "\x1f")
+	})
+}
@@ -0,0 +1,29 @@
+package httpauth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIDExtractor(t *testing.T) {
+	r := httptest.NewRequest("POST", "/login?username=user", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if id := RemoteIP.Extract(r); id != "192.0.2.1" {
+		t.Errorf("expected 192.0.2.1; got %v", id)
+	}
+	if id := ForwardedFor.Extract(r); id != "192.0.2.1" {
+		t.Errorf("expected 192.0.2.1; got %v", id)
+	}
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.2")
+	if id := ForwardedFor.Extract(r); id != "203.0.113.7" {
+		t.Errorf("expected 203.0.113.7; got %v", id)
+	}
+	if id := FormField("username").Extract(r); id != "user" {
+		t.Errorf("expected user; got %v", id)
+	}
+	id := Compose(RemoteIP, FormField("username")).Extract(r)
+	if s, ok := id.(string); !ok || !strings.Contains(s, "192.0.2.1") || !strings.Contains(s, "user") {
+		t.Errorf("unexpected composed id: %q", id)
+	}
+}
@@ -0,0 +1,28 @@
+package httpauth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/sunshineplan/password"
+)
+
+// SetRateLimitHeaders sets X-RateLimit-Limit and X-RateLimit-Remaining from the
+// lockout status s, and Retry-After in whole seconds, rounded up, when s is locked.
+func SetRateLimitHeaders(h http.Header, s password.LockoutStatus) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.Max))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(max(s.Max-s.Attempts, 0)))
+	if s.Locked {
+		h.Set("Retry-After", strconv.Itoa(s.RetryAfterSeconds()))
+	} else {
+		h.Del("Retry-After")
+	}
+}
+
+// WriteRateLimitHeaders sets the rate limit headers of w for id, see SetRateLimitHeaders.
+// It reports against the limiter's maximum attempts: for a Passworder with separate
+// hash or plaintext maximums, call SetRateLimitHeaders with its StatusHashed or
+// StatusPlain instead.
+func WriteRateLimitHeaders(w http.ResponseWriter, l *password.AttemptLimiter, id any) {
+	SetRateLimitHeaders(w.Header(), l.Status(id))
+}
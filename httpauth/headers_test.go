@@ -0,0 +1,30 @@
+package httpauth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sunshineplan/password"
+)
+
+func TestWriteRateLimitHeaders(t *testing.T) {
+	p := password.New(time.Minute, 2, nil)
+	w := httptest.NewRecorder()
+	p.Compare("id", "password", "wrongpassword")
+	WriteRateLimitHeaders(w, p.AttemptLimiter, "id")
+	if limit, remaining := w.Header().Get("X-RateLimit-Limit"), w.Header().Get("X-RateLimit-Remaining"); limit != "2" || remaining != "1" {
+		t.Errorf("expected 2 1; got %s %s", limit, remaining)
+	}
+	if v := w.Header().Get("Retry-After"); v != "" {
+		t.Errorf("expected no Retry-After; got %s", v)
+	}
+	p.Compare("id", "password", "wrongpassword")
+	WriteRateLimitHeaders(w, p.AttemptLimiter, "id")
+	if remaining := w.Header().Get("X-RateLimit-Remaining"); remaining != "0" {
+		t.Errorf("expected 0; got %s", remaining)
+	}
+	if v := w.Header().Get("Retry-After"); v != "60" {
+		t.Errorf("expected 60; got %s", v)
+	}
+}
@@ -0,0 +1,49 @@
+package password
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// DecryptHybrid decrypts a password encrypted with AES-GCM under a session key which
+// is itself encrypted with RSA PKCS #1 v1.5, for passwords longer than the RSA key
+// allows. wrappedKey, nonce and ciphertext are base64 (standard encoding), the session
+// key must be 16, 24 or 32 bytes, and the ciphertext includes the GCM tag.
+func DecryptHybrid(priv *rsa.PrivateKey, wrappedKey, nonce, ciphertext string) (string, error) {
+	return DecryptHybridWithEncoding(priv, wrappedKey, nonce, ciphertext, StdEncoding)
+}
+
+// DecryptHybridWithEncoding is like DecryptHybrid, but its inputs are encoded with enc.
+func DecryptHybridWithEncoding(priv *rsa.PrivateKey, wrappedKey, nonce, ciphertext string, enc Encoding) (string, error) {
+	key, err := DecryptPKCS1v15WithEncoding(priv, wrappedKey, enc)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	iv, err := enc.DecodeString(nonce)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidCiphertextEncoding, err)
+	}
+	if len(iv) != aead.NonceSize() {
+		return "", errors.New("invalid nonce size")
+	}
+	data, err := enc.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidCiphertextEncoding, err)
+	}
+	plain, err := aead.Open(nil, iv, data, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,47 @@
+package password
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDecryptHybrid(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	wrapped, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	password := strings.Repeat("password", 64)
+	ciphertext := aead.Seal(nil, nonce, []byte(password), nil)
+
+	enc := base64.StdEncoding.EncodeToString
+	if s, err := DecryptHybrid(priv, enc(wrapped), enc(nonce), enc(ciphertext)); err != nil {
+		t.Fatal(err)
+	} else if s != password {
+		t.Errorf("expected %q; got %q", password, s)
+	}
+	ciphertext[0] ^= 1
+	if _, err := DecryptHybrid(priv, enc(wrapped), enc(nonce), enc(ciphertext)); err == nil {
+		t.Error("expected error for tampered ciphertext; got nil")
+	}
+}
@@ -0,0 +1,51 @@
+package password
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTooFast is returned when an attempt follows the previous attempt of the same id
+// within the interval set by SetMinAttemptInterval.
+var ErrTooFast = errors.New("password attempts too fast")
+
+// attemptTimes remembers the time of the last admitted attempt of each id.
+type attemptTimes struct {
+	mu    sync.Mutex
+	m     map[any]time.Time
+	sweep time.Time
+}
+
+// SetMinAttemptInterval makes comparisons return ErrTooFast, without comparing or
+// counting an attempt, when the previous attempt of the id was less than d ago, which
+// throttles automated guessing without locking the id out. Rejected attempts do not
+// restart the interval, so that flooding an id cannot keep its owner out. Zero d
+// disables it.
+func (p *Passworder) SetMinAttemptInterval(d time.Duration) {
+	p.minInterval = d
+	if d > 0 && p.attempted == nil {
+		p.attempted = &attemptTimes{m: make(map[any]time.Time)}
+	}
+}
+
+// admit reports whether id may attempt now, and if so records the attempt.
+func (p *Passworder) admit(id any) bool {
+	t := p.attempted
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := p.now()
+	if last, ok := t.m[id]; ok && now.Sub(last) < p.minInterval {
+		return false
+	}
+	if now.After(t.sweep) {
+		for k, last := range t.m {
+			if now.Sub(last) >= p.minInterval {
+				delete(t.m, k)
+			}
+		}
+		t.sweep = now.Add(sweepInterval)
+	}
+	t.m[id] = now
+	return true
+}
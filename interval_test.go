@@ -0,0 +1,31 @@
+package password
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMinAttemptInterval(t *testing.T) {
+	now := time.Now()
+	p := New(24*time.Hour, 5, nil)
+	p.now = func() time.Time { return now }
+	p.SetMinAttemptInterval(2 * time.Second)
+	if err := p.Compare("id", "password", "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("expected ErrIncorrectPassword; got %v", err)
+	}
+	now = now.Add(time.Second)
+	if err := p.Compare("id", "password", "password"); err != ErrTooFast {
+		t.Fatalf("expected ErrTooFast; got %v", err)
+	}
+	if v, _ := p.Attempts("id"); v.Count != 1 {
+		t.Errorf("expected throttled attempt not counted; got %d", v.Count)
+	}
+	if err := p.Compare("other", "password", "password"); err != nil {
+		t.Errorf("expected other id not throttled; got %v", err)
+	}
+	now = now.Add(time.Second)
+	if err := p.Compare("id", "password", "password"); err != nil {
+		t.Errorf("expected attempt admitted after interval; got %v", err)
+	}
+}
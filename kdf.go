@@ -0,0 +1,56 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDFParams is the parameters of DeriveKey.
+type KDFParams struct {
+	// Algorithm is "argon2id", the default if empty, or "scrypt".
+	Algorithm string
+
+	// Memory (KiB), Time and Threads are the argon2id parameters.
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+
+	// N, R and P are the scrypt parameters, N must be a power of two.
+	N, R, P int
+}
+
+// DefaultKDFParams is the argon2id parameters of DefaultArgon2Params for DeriveKey.
+var DefaultKDFParams = KDFParams{
+	Algorithm: "argon2id",
+	Memory:    DefaultArgon2Params.Memory,
+	Time:      DefaultArgon2Params.Time,
+	Threads:   DefaultArgon2Params.Threads,
+}
+
+// DeriveKey derives a keyLen bytes key from password and salt, e.g. to encrypt user data
+// with a key only the user's password unlocks. salt must be random, at least 8 bytes,
+// and stored with the data. Unlike HashPassword, it applies no pepper, normalization or
+// policy and the result is not a verifier: never store the key, nor use the same salt
+// and parameters as a stored hash of the password.
+func DeriveKey(password string, salt []byte, keyLen int, params KDFParams) ([]byte, error) {
+	if len(salt) < 8 {
+		return nil, errors.New("salt too short")
+	}
+	if keyLen <= 0 {
+		return nil, errors.New("invalid key length")
+	}
+	switch params.Algorithm {
+	case "", "argon2id":
+		if params.Memory == 0 || params.Time == 0 || params.Threads == 0 {
+			return nil, errors.New("invalid argon2 parameters")
+		}
+		return argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(keyLen)), nil
+	case "scrypt":
+		return scrypt.Key([]byte(password), salt, params.N, params.R, params.P, keyLen)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, params.Algorithm)
+	}
+}
@@ -0,0 +1,42 @@
+package password
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 7914 section 12.
+	key, err := DeriveKey("pleaseletmein", []byte("SodiumChloride"), 64, KDFParams{Algorithm: "scrypt", N: 16384, R: 8, P: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := hex.EncodeToString(key); s != "7023bdcb3afd7348461c06cd81fd38ebfda8fbba904f8e3ea9b543f6545da1f2"+
+		"d5432955613f0fcf62d49705242a9af9e61e85dc0d651e40dfcf017b45575887" {
+		t.Errorf("unexpected scrypt key %s", s)
+	}
+	if _, err := DeriveKey("password", []byte("NaCl"), 64, DefaultKDFParams); err == nil {
+		t.Error("expected short salt rejected")
+	}
+
+	params := KDFParams{Memory: 1024, Time: 1, Threads: 1}
+	salt := []byte("0123456789abcdef")
+	a, err := DeriveKey("password", salt, 32, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 32 {
+		t.Errorf("expected 32 bytes key; got %d", len(a))
+	}
+	if b, _ := DeriveKey("password", salt, 32, params); !bytes.Equal(a, b) {
+		t.Error("expected deterministic key")
+	}
+	if b, _ := DeriveKey("password", []byte("fedcba9876543210"), 32, params); bytes.Equal(a, b) {
+		t.Error("expected salt to change the key")
+	}
+	if _, err := DeriveKey("password", salt, 32, KDFParams{Algorithm: "md5"}); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("expected ErrUnknownAlgorithm; got %v", err)
+	}
+}
@@ -0,0 +1,537 @@
+package password
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Record is the failed attempts record of an id.
+type Record struct {
+	Count      int
+	FirstFail  time.Time
+	LastFail   time.Time
+	LastSource string
+
+	LastSuccess time.Time
+
+	// Refilled is the time of the last token refill in rate limit mode,
+	// Window is the buckets in sliding window mode, and Expires is when the
+	// record is to be dropped. They are kept by stores.
+	Refilled time.Time
+	Window   []WindowBucket
+	Expires  time.Time
+}
+
+// AttemptLimiter counts failed attempts per id and locks an id out after the
+// maximum attempts, independently of what is being verified, so that it can guard
+// API keys, OTPs or PINs as well. Passworder embeds one for its lockout.
+type AttemptLimiter struct {
+	// mu serializes read-modify-write of records.
+	mu    sync.Mutex
+	cache Store
+	dur   time.Duration
+	max   int
+
+	jitter time.Duration
+	rand   io.Reader // nil means the package source, see SetTestRand
+
+	success time.Duration
+
+	logger *slog.Logger
+	onLock func(id any)
+
+	redactor func(id any) string
+
+	persister func(id any, count int, expiry time.Time) error
+
+	allowlist map[any]struct{}
+
+	refill time.Duration
+
+	peers *peers
+
+	maxIDs int // of the in-memory store
+
+	buckets int // sliding window buckets, zero means a fixed window
+
+	// failures and lockouts are totals since creation, guarded by mu.
+	failures uint64
+	lockouts uint64
+
+	now func() time.Time
+}
+
+// NewAttemptLimiter returns an AttemptLimiter which locks an id out after n failed
+// attempts, until d after its first failure.
+func NewAttemptLimiter(d time.Duration, n int) *AttemptLimiter {
+	l := &AttemptLimiter{dur: d, max: n, now: time.Now}
+	l.SetStore(nil)
+	return l
+}
+
+// Fail records a failed attempt of id and returns its failed attempts.
+func (l *AttemptLimiter) Fail(id any) int { return l.record(id, 1, l.max, "") }
+
+// Succeed clears the failed attempts of id.
+func (l *AttemptLimiter) Succeed(id any) { l.succeed(id) }
+
+// Exceeded reports whether id has reached the maximum attempts.
+func (l *AttemptLimiter) Exceeded(id any) bool { return l.IsMaxAttempts(id) }
+
+func (l *AttemptLimiter) SetDuration(d time.Duration) { l.dur = d }
+func (l *AttemptLimiter) SetMaxAttempts(n int)        { l.max = n }
+
+// SetKeepLastSuccess keeps a zero count record with the time of the last successful
+// comparison for d after success or Reset, instead of deleting the record.
+// Zero d disables it.
+func (l *AttemptLimiter) SetKeepLastSuccess(d time.Duration) { l.success = d }
+
+// SetLogger sets the logger for lockouts and decryption failures, nil disables logging.
+// Ids are logged redacted, passwords and keys are never logged.
+func (l *AttemptLimiter) SetLogger(logger *slog.Logger) { l.logger = logger }
+
+func (l *AttemptLimiter) log(level slog.Level, msg string, id any, attrs ...slog.Attr) {
+	if l.logger == nil {
+		return
+	}
+	l.logger.LogAttrs(context.Background(), level, msg, append([]slog.Attr{slog.String("id", l.redact(id))}, attrs...)...)
+}
+
+// SetIDRedactor sets the function which redacts ids for logging, e.g. to mask the local
+// part of emails instead, nil restores RedactID.
+func (l *AttemptLimiter) SetIDRedactor(fn func(id any) string) { l.redactor = fn }
+
+func (l *AttemptLimiter) redact(id any) string {
+	if l.redactor == nil {
+		return RedactID(id)
+	}
+	return l.redactor(id)
+}
+
+// RedactID returns a truncated SHA-256 digest of id, which is stable for correlation
+// but does not reveal the username or email it is made of, e.g. for audit logs.
+// It is how ids are logged by default, see SetIDRedactor.
+func RedactID(id any) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%T:%v", id, id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// SetAllowlist sets ids which are never locked out, such as service accounts.
+// Their failed attempts are not counted, comparisons still return ErrIncorrectPassword.
+func (l *AttemptLimiter) SetAllowlist(ids ...any) {
+	l.allowlist = make(map[any]struct{}, len(ids))
+	for _, id := range ids {
+		if validID(id) {
+			l.allowlist[id] = struct{}{}
+		}
+	}
+}
+
+// allowed reports whether id is never counted: allowlisted or unusable as a map key.
+func (l *AttemptLimiter) allowed(id any) bool {
+	if !validID(id) {
+		return true
+	}
+	_, ok := l.allowlist[id]
+	return ok
+}
+
+// validID reports whether id is comparable, so that it can be used as a map key
+// without panicking, which a slice or a struct holding one cannot.
+func validID(id any) bool {
+	return id == nil || reflect.ValueOf(id).Comparable()
+}
+
+// SetLockoutJitter adds a random duration in [0, max] to each entry's lifetime,
+// so that ids locked out together are not unlocked at the same moment.
+func (l *AttemptLimiter) SetLockoutJitter(max time.Duration) { l.jitter = max }
+
+func (l *AttemptLimiter) ttl() time.Duration {
+	d := l.dur
+	if l.refill > 0 {
+		d = time.Duration(l.max) * l.refill
+	}
+	if l.jitter <= 0 {
+		return d
+	}
+	r := l.rand
+	if r == nil {
+		r = random
+	}
+	n, err := rand.Int(r, big.NewInt(int64(l.jitter)+1))
+	if err != nil {
+		return d
+	}
+	return d + time.Duration(n.Int64())
+}
+
+// get returns id's record as of now.
+func (l *AttemptLimiter) get(id any) (Record, bool) {
+	if !validID(id) {
+		return Record{}, false
+	}
+	v, ok := l.cache.Get(id)
+	if ok {
+		now := l.now()
+		l.refillRecord(&v, now)
+		l.slideRecord(&v, now)
+	}
+	return v, ok
+}
+
+// record adds n failures to id, which is locked out at max.
+func (l *AttemptLimiter) record(id any, n, max int, source string) int {
+	if l.allowed(id) {
+		return 0
+	}
+	v, locked := l.increment(id, n, max, source)
+	l.broadcast(id, v.Count, v.Expires)
+	// The crossing is decided under the lock, so that onLock fires once per lockout
+	// however many failures race, and it is called after unlocking so that it can
+	// use the limiter.
+	if locked && l.onLock != nil {
+		l.onLock(id)
+	}
+	return v.Count
+}
+
+func (l *AttemptLimiter) increment(id any, n, max int, source string) (v Record, locked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if s, ok := l.cache.(Incrementer); ok && l.refill == 0 && l.buckets == 0 {
+		if v, prev, ok := l.incrementAtomic(s, id, n, max, source, now); ok {
+			return v, l.recorded(id, v, prev, max)
+		}
+	}
+	// A record expires a fixed window after its first failure, except that
+	// the token bucket keeps it as long as it is not refilled.
+	v, _ = l.get(id)
+	if v.Count == 0 {
+		v.FirstFail = now
+		v.Refilled = now
+		v.Expires = now.Add(l.ttl())
+	} else if l.refill > 0 {
+		v.Expires = now.Add(l.ttl())
+	}
+	prev := v.Count
+	// The count is capped at max so that it cannot grow without bound under sustained failures.
+	if v.Count += n; max > 0 && v.Count > max {
+		v.Count = max
+	}
+	if l.buckets > 0 {
+		l.addToWindow(&v, now, v.Count-prev)
+	}
+	v.LastFail = now
+	v.LastSource = source
+	l.cache.Set(id, v)
+	return v, l.recorded(id, v, prev, max)
+}
+
+// incrementAtomic adds n to id's count with the store's Increment, ok is false if it
+// failed, in which case the count is read and written back as for other stores.
+func (l *AttemptLimiter) incrementAtomic(s Incrementer, id any, n, max int, source string, now time.Time) (v Record, prev int, ok bool) {
+	v, prev, err := s.Increment(id, n, max, Record{FirstFail: now, LastFail: now, LastSource: source, Expires: now.Add(l.ttl())})
+	if err != nil {
+		l.log(slog.LevelWarn, "failed to increment attempts", id, slog.String("error", err.Error()))
+		return Record{}, 0, false
+	}
+	// Guard the cap against a store which does not apply it.
+	if max > 0 && v.Count > max {
+		v.Count = max
+	}
+	return v, prev, true
+}
+
+// recorded does the bookkeeping of a failure which took id's count from prev to v.Count,
+// and reports whether it reached max.
+func (l *AttemptLimiter) recorded(id any, v Record, prev, max int) (locked bool) {
+	l.persist(id, v.Count, v.Expires)
+	l.failures++
+	if locked = prev < max && v.Count >= max; locked {
+		l.lockouts++
+		l.log(slog.LevelWarn, "exceeded maximum password attempts", id, slog.Int("attempts", v.Count))
+	}
+	return
+}
+
+// SetOnLock sets a function called once when an id reaches the maximum password
+// attempts, e.g. to notify the account owner, but not on later attempts while it
+// stays locked. It is called synchronously from the failing call.
+func (l *AttemptLimiter) SetOnLock(fn func(id any)) { l.onLock = fn }
+
+// SetPersister sets a function called with the new count and expiry of an id whenever
+// they change, a zero count meaning the id is cleared, so that the counts can be written
+// through to a database. It is called synchronously while the limiter is locked, so that
+// changes are persisted in order, and should be fast. Its errors are logged.
+func (l *AttemptLimiter) SetPersister(fn func(id any, count int, expiry time.Time) error) {
+	l.persister = fn
+}
+
+func (l *AttemptLimiter) persist(id any, count int, expiry time.Time) {
+	if l.persister == nil {
+		return
+	}
+	if err := l.persister(id, count, expiry); err != nil {
+		l.log(slog.LevelWarn, "failed to persist attempts", id, slog.String("error", err.Error()))
+	}
+}
+
+func (l *AttemptLimiter) IsMaxAttempts(id any) bool { return l.exceeded(id, l.max) }
+
+// exceeded reports whether id has reached max attempts.
+func (l *AttemptLimiter) exceeded(id any, max int) bool {
+	n, ok := l.count(id)
+	return ok && n >= max
+}
+
+// count returns the local and remote failed attempts of id, ok is false if it has no
+// record anywhere or is never counted.
+func (l *AttemptLimiter) count(id any) (n int, ok bool) {
+	if l.allowed(id) {
+		return 0, false
+	}
+	v, ok := l.get(id)
+	remote := l.remoteCount(id)
+	return v.Count + remote, ok || remote > 0
+}
+
+// Decrement gives back n attempts to id, e.g. after a solved challenge,
+// the count does not fall below zero and its lifetime is renewed.
+func (l *AttemptLimiter) Decrement(id any, n int) {
+	if n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	v, ok := l.get(id)
+	if !ok {
+		return
+	}
+	if v.Count -= n; v.Count < 0 {
+		v.Count = 0
+	}
+	if l.buckets > 0 {
+		l.removeFromWindow(&v, n)
+	} else {
+		v.Expires = l.now().Add(l.ttl())
+	}
+	l.cache.Set(id, v)
+	l.persist(id, v.Count, v.Expires)
+}
+
+// FilterLocked partitions ids into those exceeded maximum password attempts and those not.
+func (l *AttemptLimiter) FilterLocked(ids []any) (locked []any, unlocked []any) {
+	for _, id := range ids {
+		if l.IsMaxAttempts(id) {
+			locked = append(locked, id)
+		} else {
+			unlocked = append(unlocked, id)
+		}
+	}
+	return
+}
+
+// WouldLockNext reports whether one more incorrect password would lock id.
+func (l *AttemptLimiter) WouldLockNext(id any) bool {
+	if l.allowed(id) {
+		return false
+	}
+	n, _ := l.count(id)
+	return n+1 >= l.max
+}
+
+// LockoutStatus is a consistent snapshot of an id's lockout status.
+type LockoutStatus struct {
+	Locked     bool          `json:"locked"`
+	RetryAfter time.Duration `json:"retryAfter"` // remaining lockout, zero if not locked
+	Attempts   int           `json:"attempts"`
+	Max        int           `json:"max"`
+}
+
+// Status returns id's lockout status.
+func (l *AttemptLimiter) Status(id any) LockoutStatus { return l.status(id, l.max) }
+
+// status returns id's lockout status with max attempts.
+func (l *AttemptLimiter) status(id any, max int) LockoutStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := LockoutStatus{Max: max}
+	if l.allowed(id) {
+		return s
+	}
+	v, ok := l.get(id)
+	remote := l.remoteUpdates(id)
+	if !ok && len(remote) == 0 {
+		return s
+	}
+	s.Attempts = v.Count
+	for _, u := range remote {
+		s.Attempts += u.Count
+	}
+	if s.Locked = s.Attempts >= max; s.Locked {
+		if v.Count < max {
+			s.RetryAfter = remoteRetryAfter(v, remote, l.now(), max)
+		} else if l.refill > 0 {
+			s.RetryAfter = v.Refilled.Add(l.refill).Sub(l.now())
+		} else if l.buckets > 0 {
+			s.RetryAfter = l.windowRetryAfter(v, l.now(), max)
+		} else {
+			s.RetryAfter = v.Expires.Sub(l.now())
+		}
+	}
+	return s
+}
+
+// RemainingAttempts returns how many incorrect passwords id may still try before lockout.
+func (l *AttemptLimiter) RemainingAttempts(id any) int {
+	s := l.Status(id)
+	return max(s.Max-s.Attempts, 0)
+}
+
+// RetryAfterSeconds returns the remaining lockout rounded up to whole seconds, as for
+// a Retry-After header, so that a sub-second remainder does not invite an immediate
+// retry. It returns 0 if not locked.
+func (s LockoutStatus) RetryAfterSeconds() int {
+	if !s.Locked || s.RetryAfter <= 0 {
+		return 0
+	}
+	return int((s.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// LockoutRetryAfterSeconds returns the remaining lockout of id rounded up to whole
+// seconds, 0 if not locked, see LockoutStatus.RetryAfterSeconds.
+func (l *AttemptLimiter) LockoutRetryAfterSeconds(id any) int {
+	return l.Status(id).RetryAfterSeconds()
+}
+
+// LockoutRemaining returns the remaining lockout of id, zero if not locked.
+func (l *AttemptLimiter) LockoutRemaining(id any) time.Duration {
+	return l.Status(id).RetryAfter
+}
+
+// Attempts returns id's incorrect password attempts record.
+func (l *AttemptLimiter) Attempts(id any) (Record, bool) {
+	return l.get(id)
+}
+
+// Reset clears the failed attempts of ids, e.g. to unlock accounts in bulk.
+func (l *AttemptLimiter) Reset(ids ...any) {
+	for _, id := range ids {
+		l.forget(id)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		l.reset(id)
+	}
+}
+
+// ResetIfBelowMax clears the failed attempts of id only if it has not reached the
+// maximum attempts, and reports whether it did, so that clearing a user's failures
+// cannot lift a lockout reached concurrently. The check and the reset are atomic
+// with respect to the failures recorded by this limiter.
+func (l *AttemptLimiter) ResetIfBelowMax(id any) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, _ := l.get(id); v.Count+l.remoteCount(id) >= l.max {
+		return false
+	}
+	l.forget(id)
+	l.reset(id)
+	return true
+}
+
+func (l *AttemptLimiter) reset(id any) {
+	if !validID(id) {
+		return
+	}
+	l.persist(id, 0, time.Time{})
+	if l.success > 0 {
+		if v, ok := l.cache.Get(id); ok && !v.LastSuccess.IsZero() {
+			l.cache.Set(id, Record{LastSuccess: v.LastSuccess, Expires: l.now().Add(l.success)})
+			return
+		}
+	}
+	l.cache.Delete(id)
+}
+
+func (l *AttemptLimiter) succeed(id any) {
+	if !validID(id) {
+		return
+	}
+	l.forget(id)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.persist(id, 0, time.Time{})
+	if l.success > 0 {
+		now := l.now()
+		l.cache.Set(id, Record{LastSuccess: now, Expires: now.Add(l.success)})
+		return
+	}
+	l.cache.Delete(id)
+}
+
+// LastSuccess returns the time of id's last successful comparison,
+// it is only recorded when SetKeepLastSuccess is enabled.
+func (l *AttemptLimiter) LastSuccess(id any) (time.Time, bool) {
+	if !validID(id) {
+		return time.Time{}, false
+	}
+	v, ok := l.cache.Get(id)
+	if !ok || v.LastSuccess.IsZero() {
+		return time.Time{}, false
+	}
+	return v.LastSuccess, true
+}
+
+// Snapshot returns a point-in-time copy of the incorrect password attempts of all
+// ids with unexpired records. It holds the store lock while copying and its size
+// grows with the number of tracked ids, so it is meant for monitoring, not hot paths.
+func (l *AttemptLimiter) Snapshot() map[any]int {
+	now := l.now()
+	m := make(map[any]int)
+	for id, v := range l.all() {
+		l.refillRecord(&v, now)
+		if l.slideRecord(&v, now); v.Count > 0 {
+			m[id] = v.Count
+		}
+	}
+	return m
+}
+
+// FailureWindowStart returns the time of the first failure of id's current failure streak.
+func (l *AttemptLimiter) FailureWindowStart(id any) (time.Time, bool) {
+	v, ok := l.get(id)
+	if !ok || v.Count == 0 {
+		return time.Time{}, false
+	}
+	return v.FirstFail, true
+}
+
+// PurgeExpired removes the expired records and returns how many were removed.
+// Expired records are never reported, but they are only swept from memory from
+// time to time, so a flood of one-off ids can be cleaned up with it right away.
+func (l *AttemptLimiter) PurgeExpired() int {
+	if s, ok := l.cache.(interface{ Purge() int }); ok {
+		return s.Purge()
+	}
+	return 0
+}
+
+// Close releases the resources of records, including the expiration goroutines of
+// the in-memory store. A closed limiter must not be reused.
+func (l *AttemptLimiter) Close() {
+	if s, ok := l.cache.(interface{ Clear() }); ok {
+		s.Clear()
+	}
+}
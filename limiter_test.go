@@ -0,0 +1,127 @@
+package password
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	l := NewAttemptLimiter(time.Minute, 3)
+	for i := 1; i <= 3; i++ {
+		if l.Exceeded("pin") {
+			t.Fatalf("#%d: expected not exceeded; got exceeded", i)
+		}
+		if n := l.Fail("pin"); n != i {
+			t.Errorf("expected %d; got %d", i, n)
+		}
+	}
+	if !l.Exceeded("pin") {
+		t.Error("expected exceeded; got not")
+	}
+	if s := l.Status("pin"); !s.Locked || s.RetryAfter <= 0 {
+		t.Errorf("expected locked status; got %+v", s)
+	}
+	l.Succeed("pin")
+	if l.Exceeded("pin") {
+		t.Error("expected not exceeded; got exceeded")
+	}
+	if _, ok := l.Attempts("pin"); ok {
+		t.Error("expected no record; got one")
+	}
+}
+
+func TestResetMany(t *testing.T) {
+	l := NewAttemptLimiter(time.Minute, 1)
+	for _, id := range []string{"a", "b", "c"} {
+		l.Fail(id)
+	}
+	l.Reset("a", "b")
+	if l.Exceeded("a") || l.Exceeded("b") {
+		t.Error("expected a and b reset")
+	}
+	if !l.Exceeded("c") {
+		t.Error("expected c still exceeded")
+	}
+}
+
+func TestPersister(t *testing.T) {
+	l := NewAttemptLimiter(time.Minute, 3)
+	type change struct {
+		id    any
+		count int
+	}
+	var changes []change
+	l.SetPersister(func(id any, count int, expiry time.Time) error {
+		if count > 0 && expiry.IsZero() {
+			t.Errorf("expected expiry for count %d", count)
+		}
+		changes = append(changes, change{id, count})
+		return nil
+	})
+	l.Fail("id")
+	l.Fail("id")
+	l.Decrement("id", 1)
+	l.Succeed("id")
+	l.Fail("other")
+	l.Reset("other")
+	expected := []change{{"id", 1}, {"id", 2}, {"id", 1}, {"id", 0}, {"other", 1}, {"other", 0}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v; got %v", expected, changes)
+	}
+}
+
+func TestResetIfBelowMax(t *testing.T) {
+	l := NewAttemptLimiter(24*time.Hour, 2)
+	l.Fail("id")
+	if !l.ResetIfBelowMax("id") {
+		t.Error("expected reset below max")
+	}
+	if _, ok := l.Attempts("id"); ok {
+		t.Error("expected attempts cleared")
+	}
+	l.Fail("id")
+	l.Fail("id")
+	if l.ResetIfBelowMax("id") {
+		t.Error("expected no reset at max")
+	}
+	if !l.IsMaxAttempts("id") {
+		t.Error("expected lockout kept")
+	}
+}
+
+func TestNonComparableID(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetSync("node", make(chanTransport, 10))
+	id := []string{"user"}
+	if err := p.Compare(id, "password", "password"); err != ErrInvalidID {
+		t.Errorf("expected ErrInvalidID; got %v", err)
+	}
+	if err := p.Compare(struct{ v any }{id}, "password", "password"); err != ErrInvalidID {
+		t.Errorf("expected ErrInvalidID; got %v", err)
+	}
+	if n := p.Fail(id); n != 0 {
+		t.Errorf("expected not counted; got %d", n)
+	}
+	if p.IsMaxAttempts(id) {
+		t.Error("expected not locked")
+	}
+	p.Reset(id)
+	p.ResetIfBelowMax(id)
+	p.SetKeepLastSuccess(time.Hour)
+	if _, ok := p.LastSuccess(id); ok {
+		t.Error("expected no last success")
+	}
+	if p.WouldLockNext(id) || p.LockoutRetryAfterSeconds(id) != 0 || p.DisplayAttempts(id) != 0 {
+		t.Error("expected no attempts")
+	}
+	if _, ok := p.FailureWindowStart(id); ok {
+		t.Error("expected no failure window")
+	}
+	p.Decrement(id, 1)
+	p.SetAllowlist(id)
+	p.ApplyUpdate(SyncUpdate{ID: id, Node: "other", Count: 1, Expires: time.Now().Add(time.Hour)})
+	if err := p.Compare(struct{ v any }{"user"}, "password", "password"); err != nil {
+		t.Errorf("expected comparable struct id accepted; got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package password
+
+import (
+	"fmt"
+	"io"
+)
+
+// WriteMetrics writes the lockout metrics in the OpenMetrics text format, so that
+// they can be scraped without a Prometheus client:
+//
+//   - password_failed_attempts_total, counter of failed attempts recorded;
+//   - password_lockouts_total, counter of ids reaching the maximum attempts;
+//   - password_tracked_ids, gauge of ids with failed attempts;
+//   - password_locked_ids, gauge of ids currently locked out.
+//
+// The counters are kept by the limiter, the gauges are computed from the store,
+// and are zero if it cannot list its records, see Store. Serve it with the
+// content type "application/openmetrics-text; version=1.0.0; charset=utf-8".
+func (l *AttemptLimiter) WriteMetrics(w io.Writer) error {
+	l.mu.Lock()
+	failures, lockouts := l.failures, l.lockouts
+	l.mu.Unlock()
+	var tracked, locked int
+	for _, n := range l.Snapshot() {
+		tracked++
+		if n >= l.max {
+			locked++
+		}
+	}
+	_, err := fmt.Fprintf(w, `# TYPE password_failed_attempts counter
+# HELP password_failed_attempts Failed password attempts recorded.
+password_failed_attempts_total %d
+# TYPE password_lockouts counter
+# HELP password_lockouts Ids reaching the maximum password attempts.
+password_lockouts_total %d
+# TYPE password_tracked_ids gauge
+# HELP password_tracked_ids Ids with failed password attempts.
+password_tracked_ids %d
+# TYPE password_locked_ids gauge
+# HELP password_locked_ids Ids locked out.
+password_locked_ids %d
+# EOF
+`, failures, lockouts, tracked, locked)
+	return err
+}
@@ -0,0 +1,32 @@
+package password
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	l := NewAttemptLimiter(24*time.Hour, 2)
+	l.Fail("a")
+	l.Fail("a")
+	l.Fail("b")
+	var b strings.Builder
+	if err := l.WriteMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		"password_failed_attempts_total 3\n",
+		"password_lockouts_total 1\n",
+		"password_tracked_ids 2\n",
+		"password_locked_ids 1\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in\n%s", line, out)
+		}
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Errorf("expected # EOF terminator; got\n%s", out)
+	}
+}
@@ -1,12 +1,14 @@
 package password
 
 import (
+	"context"
 	"crypto/rsa"
-	"encoding/base64"
 	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 var std = New(24*time.Hour, 5, nil)
@@ -17,40 +19,273 @@ func Default() *Passworder { return std }
 func SetDuration(d time.Duration) { std.SetDuration(d) }
 func SetMaxAttempts(n int)        { std.SetMaxAttempts(n) }
 func SetKey(key *rsa.PrivateKey)  { std.SetKey(key) }
+func SetEncoding(enc Encoding)    { std.SetEncoding(enc) }
+func SetCost(cost int)            { std.SetCost(cost) }
+func SetMaxVerifyCost(n int)      { std.SetMaxVerifyCost(n) }
+
+func SetLockoutJitter(max time.Duration)   { std.SetLockoutJitter(max) }
+func SetStrict(strict bool)                { std.SetStrict(strict) }
+func SetKeepLastSuccess(d time.Duration)   { std.SetKeepLastSuccess(d) }
+func SetCompareTimeout(d time.Duration)    { std.SetCompareTimeout(d) }
+func SetMinResponseTime(d time.Duration)   { std.SetMinResponseTime(d) }
+func SetResultCache(ttl time.Duration)     { std.SetResultCache(ttl) }
+func SetLogger(logger *slog.Logger)        { std.SetLogger(logger) }
+func SetOnLock(fn func(id any))            { std.SetOnLock(fn) }
+func SetPepper(pepper []byte)              { std.SetPepper(pepper) }
+func RotatePepper(newPepper []byte)        { std.RotatePepper(newPepper) }
+func SetPeppers(peppers [][]byte)          { std.SetPeppers(peppers) }
+func SetAllowlist(ids ...any)              { std.SetAllowlist(ids...) }
+func SetClientHashed(clientHashed bool)    { std.SetClientHashed(clientHashed) }
+func SetOpaqueErrors(opaque bool)          { std.SetOpaqueErrors(opaque) }
+func SetNormalizer(fn func(string) string) { std.SetNormalizer(fn) }
+func SetTrimSpace(trim bool)               { std.SetTrimSpace(trim) }
+func SetRejectLongPasswords(reject bool)   { std.SetRejectLongPasswords(reject) }
+func SetRequireUTF8(require bool)          { std.SetRequireUTF8(require) }
+func SetPlaintextFallback(fallback bool)   { std.SetPlaintextFallback(fallback) }
+func SetLockOnDecryptError(lock bool)      { std.SetLockOnDecryptError(lock) }
+func SetClientHMACKey(key []byte)          { std.SetClientHMACKey(key) }
+func SetArgon2Params(params *Argon2Params) { std.SetArgon2Params(params) }
+func SetPolicy(policy Policy)              { std.SetPolicy(policy) }
+
+// SetPrefixPepper sets a secret prepended to passwords for legacy bcrypt(pepper + password) schemes.
+func SetPrefixPepper(pepper string) { std.SetPrefixPepper(pepper) }
+
+// SetPepperHash sets the hash function of the pepper HMAC, nil means sha256.New.
+func SetPepperHash(fn func() hash.Hash) { std.SetPepperHash(fn) }
+
+// SetMaxTrackedIDs bounds the in-memory store to n ids, evicting the least recently used.
+func SetMaxTrackedIDs(n int) { std.SetMaxTrackedIDs(n) }
+
+// SetTokenKey makes comparisons take the password as the claim of a JWT signed with key.
+func SetTokenKey(key any, claim string) { std.SetTokenKey(key, claim) }
+
+// SetResetOnChange sets whether ChangePassword clears the failed attempts of the id.
+func SetResetOnChange(reset bool) { std.SetResetOnChange(reset) }
+
+// SetMaxAttemptsHashed sets the maximum password attempts of hash comparisons.
+func SetMaxAttemptsHashed(n int) { std.SetMaxAttemptsHashed(n) }
+
+// SetMaxAttemptsPlain sets the maximum password attempts of plaintext comparisons.
+func SetMaxAttemptsPlain(n int) { std.SetMaxAttemptsPlain(n) }
+
+// SetHashVersion sets the version HashVersioned tags hashes with.
+func SetHashVersion(version int) { std.SetHashVersion(version) }
+
+// SetCaptchaThreshold sets the failed attempts from which a CAPTCHA is required.
+func SetCaptchaThreshold(n int) { std.SetCaptchaThreshold(n) }
+
+// SetIDRedactor sets the function which redacts ids for logging, nil restores RedactID.
+func SetIDRedactor(fn func(id any) string) { std.SetIDRedactor(fn) }
+
+// SetMinAttemptInterval sets the minimum interval between attempts of an id.
+func SetMinAttemptInterval(d time.Duration) { std.SetMinAttemptInterval(d) }
+
+func SetRateLimit(capacity int, refill time.Duration) { std.SetRateLimit(capacity, refill) }
+func SetAcceptedPrefixes(prefixes []string)           { std.SetAcceptedPrefixes(prefixes) }
+
+// SetSlidingWindow switches the lockout to a sliding window of total split into buckets.
+func SetSlidingWindow(buckets int, total time.Duration) { std.SetSlidingWindow(buckets, total) }
+
+// SetDefaultStore sets the store of the records of the package-level functions,
+// e.g. a Redis backed Store shared by several instances.
+func SetDefaultStore(s Store) { std.SetStore(s) }
+
+// SetPersister sets a function called with the new count and expiry of an id whenever they change.
+func SetPersister(fn func(id any, count int, expiry time.Time) error) {
+	std.SetPersister(fn)
+}
 
 // IsMaxAttempts checks id exceeded maximum password attempts or not.
 func IsMaxAttempts(id any) bool { return std.IsMaxAttempts(id) }
 
-// Reset resets id's incorrect password count.
-func Reset(id any) { std.Reset(id) }
+// FilterLocked partitions ids into those exceeded maximum password attempts and those not.
+func FilterLocked(ids []any) (locked []any, unlocked []any) { return std.FilterLocked(ids) }
+
+// WouldLockNext reports whether one more incorrect password would lock id.
+func WouldLockNext(id any) bool { return std.WouldLockNext(id) }
+
+// Status returns id's lockout status.
+func Status(id any) LockoutStatus { return std.Status(id) }
+
+// StatusHashed returns id's lockout status against the maximum attempts of hash comparisons.
+func StatusHashed(id any) LockoutStatus { return std.StatusHashed(id) }
+
+// StatusPlain returns id's lockout status against the maximum attempts of plaintext comparisons.
+func StatusPlain(id any) LockoutStatus { return std.StatusPlain(id) }
+
+// RemainingAttempts returns how many incorrect passwords id may still try before lockout.
+func RemainingAttempts(id any) int { return std.RemainingAttempts(id) }
+
+// LockoutRemaining returns the remaining lockout of id, zero if not locked.
+func LockoutRemaining(id any) time.Duration { return std.LockoutRemaining(id) }
+
+// Attempts returns id's incorrect password attempts record.
+func Attempts(id any) (Record, bool) { return std.Attempts(id) }
+
+// LastSuccess returns the time of id's last successful comparison.
+func LastSuccess(id any) (time.Time, bool) { return std.LastSuccess(id) }
+
+// WriteMetrics writes the lockout metrics in the OpenMetrics text format.
+func WriteMetrics(w io.Writer) error { return std.WriteMetrics(w) }
+
+// PurgeExpired removes the expired records and returns how many were removed.
+func PurgeExpired() int { return std.PurgeExpired() }
+
+// Snapshot returns a point-in-time copy of the incorrect password attempts of all ids.
+func Snapshot() map[any]int { return std.Snapshot() }
+
+// FailureWindowStart returns the time of the first failure of id's current failure streak.
+func FailureWindowStart(id any) (time.Time, bool) { return std.FailureWindowStart(id) }
+
+// Decrement gives back n attempts to id.
+func Decrement(id any, n int) { std.Decrement(id, n) }
+
+// Reset resets the incorrect password count of ids.
+func Reset(ids ...any) { std.Reset(ids...) }
+
+// LockoutRetryAfterSeconds returns the remaining lockout of id rounded up to whole seconds.
+func LockoutRetryAfterSeconds(id any) int { return std.LockoutRetryAfterSeconds(id) }
+
+// ResetIfBelowMax clears the failed attempts of id only if it has not reached the maximum attempts.
+func ResetIfBelowMax(id any) bool { return std.ResetIfBelowMax(id) }
 
 // Compare compares passwords equivalent, id is used to record password attempts.
+//
+// Attempts are counted per id, so all comparisons with the same id share one counter.
+// In particular every call with a nil or empty id shares a single counter, and enough
+// failures locks them out together. Use SetStrict to reject such ids.
 func Compare(id any, key string, password string) error {
 	return std.Compare(id, key, password)
 }
 
 // CompareHashAndPassword compares passwords equivalent, id is used to record password attempts.
-// hash must be a bcrypt hashed password.
+// hash must be a bcrypt or argon2 (PHC string format) hashed password.
 func CompareHashAndPassword(id any, hash string, password string) error {
 	return std.CompareHashAndPassword(id, hash, password)
 }
 
-// HashPassword returns the bcrypt hash of the password.
-func HashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashed), nil
+// CompareAndUpgrade is like CompareHashAndPassword, rehash reports whether the hash
+// was made with a previous pepper and should be replaced.
+func CompareAndUpgrade(id any, hash string, password string) (rehash bool, err error) {
+	return std.CompareAndUpgrade(id, hash, password)
+}
+
+// CompareWithKey is like Compare, but decrypts password with priv instead of the configured key.
+func CompareWithKey(id any, key, password string, priv *rsa.PrivateKey) error {
+	return std.CompareWithKey(id, key, password, priv)
 }
 
+// CompareHashAndPasswordWithKey is like CompareHashAndPassword, but decrypts password
+// with priv instead of the configured key.
+func CompareHashAndPasswordWithKey(id any, hash, password string, priv *rsa.PrivateKey) error {
+	return std.CompareHashAndPasswordWithKey(id, hash, password, priv)
+}
+
+// CompareHashAndPasswordPlain is like CompareHashAndPassword, but password is not decrypted.
+func CompareHashAndPasswordPlain(id any, hash, plaintext string) error {
+	return std.CompareHashAndPasswordPlain(id, hash, plaintext)
+}
+
+// CompareAndResetOther is like CompareHashAndPassword, but on success it also resets otherID.
+func CompareAndResetOther(id any, hash, password string, otherID any) error {
+	return std.CompareAndResetOther(id, hash, password, otherID)
+}
+
+// CompareHashBytesAndPassword is like CompareHashAndPassword, but hash is given as bytes.
+func CompareHashBytesAndPassword(id any, hash []byte, password string) error {
+	return std.CompareHashBytesAndPassword(id, hash, password)
+}
+
+// CompareHashAndPasswordWithDisplay is like CompareHashAndPassword, but an incorrect
+// password is also counted for displayID, see DisplayAttempts.
+func CompareHashAndPasswordWithDisplay(id, displayID any, hash, password string) error {
+	return std.CompareHashAndPasswordWithDisplay(id, displayID, hash, password)
+}
+
+// DisplayAttempts returns the failed attempts counted for displayID.
+func DisplayAttempts(displayID any) int { return std.DisplayAttempts(displayID) }
+
+// CompareBefore is like Compare, but fails with ErrComparisonTimeout past deadline.
+func CompareBefore(id any, key, password string, deadline time.Time) error {
+	return std.CompareBefore(id, key, password, deadline)
+}
+
+// CompareHashAndPasswordBefore is like CompareHashAndPassword, but fails with
+// ErrComparisonTimeout if the comparison does not complete before deadline.
+func CompareHashAndPasswordBefore(id any, hash, password string, deadline time.Time) error {
+	return std.CompareHashAndPasswordBefore(id, hash, password, deadline)
+}
+
+// CompareWithCaptchaSolved is like Compare, for a client which has solved a CAPTCHA.
+func CompareWithCaptchaSolved(id any, key, password string) error {
+	return std.CompareWithCaptchaSolved(id, key, password)
+}
+
+// CompareHashAndPasswordWithCaptchaSolved is like CompareHashAndPassword, for a client
+// which has solved a CAPTCHA.
+func CompareHashAndPasswordWithCaptchaSolved(id any, hash, password string) error {
+	return std.CompareHashAndPasswordWithCaptchaSolved(id, hash, password)
+}
+
+// HashPassword returns the hash of the password in the configured algorithm.
+func HashPassword(password string) (string, error) { return std.HashPassword(password) }
+
+// HashPasswordBytes returns the hash of the password in the configured algorithm as bytes.
+func HashPasswordBytes(password string) ([]byte, error) { return std.HashPasswordBytes(password) }
+
+// ChangePassword returns the hash of newPassword and clears the failed attempts of id.
+func ChangePassword(id any, newPassword string) (string, error) {
+	return std.ChangePassword(id, newPassword)
+}
+
+// ValidateAndHash returns the hash of the password and the policy warnings about it.
+func ValidateAndHash(password string) (hash string, warnings []string, err error) {
+	return std.ValidateAndHash(password)
+}
+
+// DummyHash returns a valid hash which no password matches, made with the configured cost.
+func DummyHash() string { return std.DummyHash() }
+
+// HashVersioned returns the hash of the password tagged with the current version.
+func HashVersioned(password string) (string, error) { return std.HashVersioned(password) }
+
+// CompareVersioned is like CompareAndUpgrade for a hash made by HashVersioned.
+func CompareVersioned(id any, hash, password string) (rehash bool, err error) {
+	return std.CompareVersioned(id, hash, password)
+}
+
+// HashPasswordExpiring returns the hash of the password which expires after ttl.
+func HashPasswordExpiring(password string, ttl time.Duration) (string, error) {
+	return std.HashPasswordExpiring(password, ttl)
+}
+
+// CompareExpiring compares a hash made by HashPasswordExpiring with password,
+// ErrPasswordExpired is returned if it matches but has expired.
+func CompareExpiring(id any, hash, password string) error {
+	return std.CompareExpiring(id, hash, password)
+}
+
+// HashPasswordAsync hashes passwords concurrently and sends the results as they complete.
+func HashPasswordAsync(ctx context.Context, passwords []string, limit int) <-chan HashResult {
+	return std.HashPasswordAsync(ctx, passwords, limit)
+}
+
+// SelfTest checks that the configured key decrypts what its public key encrypts.
+func SelfTest() error { return std.SelfTest() }
+
+// DecryptPKCS1v15 decrypts a base64 (standard encoding) ciphertext with priv.
 func DecryptPKCS1v15(priv *rsa.PrivateKey, ciphertext string) (string, error) {
+	return DecryptPKCS1v15WithEncoding(priv, ciphertext, StdEncoding)
+}
+
+// DecryptPKCS1v15WithEncoding decrypts a ciphertext encoded with enc with priv.
+func DecryptPKCS1v15WithEncoding(priv *rsa.PrivateKey, ciphertext string, enc Encoding) (string, error) {
 	if priv == nil {
 		return "", errors.New("no private key")
 	}
-	cipher, err := base64.StdEncoding.DecodeString(ciphertext)
+	cipher, err := enc.DecodeString(ciphertext)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %w", ErrInvalidCiphertextEncoding, err)
 	}
 	plain, err := rsa.DecryptPKCS1v15(nil, priv, cipher)
 	if err != nil {
@@ -1,10 +1,16 @@
 package password
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
 	"errors"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -24,22 +30,22 @@ func TestCompare(t *testing.T) {
 	if err := p.CompareHashAndPassword("", hashed, password); err != nil {
 		t.Error(err)
 	}
-	if v, _ := p.cache.Get(""); v != 0 {
-		t.Errorf("expected 0; got %d", v)
+	if v, _ := p.cache.Get(""); v.Count != 0 {
+		t.Errorf("expected 0; got %d", v.Count)
 	}
 	err = p.CompareHashAndPassword("", hashed, "wrongpassword")
 	if err == nil {
 		t.Error("expected non-nil err; got nil")
 	}
-	if v, _ := p.cache.Get(""); v != 1 {
-		t.Errorf("expected 1; got %d", v)
+	if v, _ := p.cache.Get(""); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
 	}
 	err = p.CompareHashAndPassword("", "bad hash", password)
 	if err == nil {
 		t.Error("expected non-nil err; got nil")
 	}
-	if v, _ := p.cache.Get(""); v != 1 {
-		t.Errorf("expected 1; got %d", v)
+	if v, _ := p.cache.Get(""); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
 	}
 }
 
@@ -68,23 +74,23 @@ func TestRSA(t *testing.T) {
 	if err := p.Compare("", password, encrypted); err != nil {
 		t.Error(err)
 	}
-	if err := p.CompareHashAndPassword("", password, encrypted); err != bcrypt.ErrHashTooShort {
+	if err := p.CompareHashAndPassword("", password, encrypted); !errors.Is(err, bcrypt.ErrHashTooShort) || !errors.Is(err, ErrInvalidHash) {
 		t.Errorf("expected non-nil err; got %v", err)
 	}
-	if v, _ := p.cache.Get(""); v != 0 {
-		t.Errorf("expected 0; got %d", v)
+	if v, _ := p.cache.Get(""); v.Count != 0 {
+		t.Errorf("expected 0; got %d", v.Count)
 	}
-	if err := p.Compare("", hashed, encrypted); err != incorrectPasswordError(1) {
+	if err := p.Compare("", hashed, encrypted); err != (incorrectPasswordError{1, nil}) {
 		t.Errorf("expected incorrect password 1; got %v", err)
 	}
-	if v, _ := p.cache.Get(""); v != 1 {
-		t.Errorf("expected 1; got %d", v)
+	if v, _ := p.cache.Get(""); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
 	}
 	if err := p.CompareHashAndPassword("", hashed, encrypted); err != nil {
 		t.Error(err)
 	}
-	if v, _ := p.cache.Get(""); v != 0 {
-		t.Errorf("expected 0; got %d", v)
+	if v, _ := p.cache.Get(""); v.Count != 0 {
+		t.Errorf("expected 0; got %d", v.Count)
 	}
 	if err := p.CompareHashAndPassword("", hashed, "BadEncryptedPassword"); err == nil {
 		t.Error("expected non-nil err; got nil")
@@ -94,6 +100,30 @@ func TestRSA(t *testing.T) {
 	}
 }
 
+func TestSelfTest(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	if err := p.SelfTest(); err == nil {
+		t.Error("expected error without key")
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetKey(priv)
+	p.SetEncoding(HexEncoding)
+	if err := p.SelfTest(); err != nil {
+		t.Error(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetKey(&rsa.PrivateKey{PublicKey: other.PublicKey, D: priv.D, Primes: priv.Primes})
+	if err := p.SelfTest(); err == nil {
+		t.Error("expected error with mismatched key")
+	}
+}
+
 func TestMaxPasswordAttempts(t *testing.T) {
 	p := New(24*time.Hour, 5, nil)
 	type info struct {
@@ -124,3 +154,852 @@ func TestMaxPasswordAttempts(t *testing.T) {
 		t.Errorf("expected ErrMaxPasswordAttempts; got %v", err)
 	}
 }
+
+func TestLockoutJitter(t *testing.T) {
+	p := New(time.Minute, 5, nil)
+	if d := p.ttl(); d != time.Minute {
+		t.Errorf("expected %s; got %s", time.Minute, d)
+	}
+	p.SetLockoutJitter(time.Second)
+	p.rand = bytes.NewReader(bytes.Repeat([]byte{0}, 64))
+	if d := p.ttl(); d != time.Minute {
+		t.Errorf("expected %s; got %s", time.Minute, d)
+	}
+	for range 100 {
+		p.rand = rand.Reader
+		if d := p.ttl(); d < time.Minute || d > time.Minute+time.Second {
+			t.Fatalf("expected ttl in [1m, 1m1s]; got %s", d)
+		}
+	}
+}
+
+func TestCompareWithMeta(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	if _, ok := p.Attempts("id"); ok {
+		t.Fatal("expected no record; got one")
+	}
+	before := time.Now()
+	if err := p.CompareWithMeta("id", "password", "wrongpassword", "127.0.0.1"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if err := p.CompareWithMeta("id", "password", "wrongpassword", "10.0.0.1"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("expected ErrIncorrectPassword; got %v", err)
+	}
+	r, ok := p.Attempts("id")
+	if !ok {
+		t.Fatal("expected record; got none")
+	}
+	if r.Count != 2 {
+		t.Errorf("expected 2; got %d", r.Count)
+	}
+	if r.LastSource != "10.0.0.1" {
+		t.Errorf("expected 10.0.0.1; got %q", r.LastSource)
+	}
+	if r.LastFail.Before(before) {
+		t.Errorf("expected last fail after %s; got %s", before, r.LastFail)
+	}
+	if err := p.CompareWithMeta("id", "password", "password", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Attempts("id"); ok {
+		t.Error("expected no record; got one")
+	}
+}
+
+func TestWouldLockNext(t *testing.T) {
+	p := New(24*time.Hour, 3, nil)
+	for i := 0; i < 2; i++ {
+		if p.WouldLockNext("id") {
+			t.Fatalf("attempt %d: expected not would lock; got would", i)
+		}
+		p.Compare("id", "password", "wrongpassword")
+	}
+	if !p.WouldLockNext("id") {
+		t.Error("expected would lock; got not")
+	}
+	if v, _ := p.cache.Get("id"); v.Count != 2 {
+		t.Errorf("expected 2; got %d", v.Count)
+	}
+
+	p.SetAllowlist("service")
+	p.Compare("service", "password", "wrongpassword")
+	p.Compare("service", "password", "wrongpassword")
+	if p.WouldLockNext("service") {
+		t.Error("expected allowlisted id never would lock; got would")
+	}
+}
+
+func TestArgumentsSwapped(t *testing.T) {
+	hashed, err := HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, nil)
+	if err := p.CompareHashAndPassword("id", "password", hashed); err == ErrArgumentsSwapped {
+		t.Error("expected not ErrArgumentsSwapped in non-strict mode")
+	}
+	p.SetStrict(true)
+	if err := p.CompareHashAndPassword("id", "password", hashed); err != ErrArgumentsSwapped {
+		t.Errorf("expected ErrArgumentsSwapped; got %v", err)
+	}
+	if err := p.CompareHashAndPassword("id", hashed, "password"); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareHashAndPassword("id", hashed, hashed); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+}
+
+func TestLastSuccess(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.Compare("id", "password", "password")
+	if _, ok := p.LastSuccess("id"); ok {
+		t.Error("expected no last success; got one")
+	}
+	p.SetKeepLastSuccess(time.Hour)
+	p.Compare("id", "password", "wrongpassword")
+	if _, ok := p.LastSuccess("id"); ok {
+		t.Error("expected no last success; got one")
+	}
+	before := time.Now()
+	if err := p.Compare("id", "password", "password"); err != nil {
+		t.Fatal(err)
+	}
+	last, ok := p.LastSuccess("id")
+	if !ok || last.Before(before) {
+		t.Fatalf("expected last success after %s; got %s, %v", before, last, ok)
+	}
+	p.Compare("id", "password", "wrongpassword")
+	if v, _ := p.cache.Get("id"); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
+	}
+	p.Reset("id")
+	if v, _ := p.cache.Get("id"); v.Count != 0 {
+		t.Errorf("expected 0; got %d", v.Count)
+	}
+	if t2, ok := p.LastSuccess("id"); !ok || !t2.Equal(last) {
+		t.Errorf("expected last success %s; got %s, %v", last, t2, ok)
+	}
+}
+
+func TestRecordCap(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, priv)
+	for range 3 {
+		if err := p.Compare("id", "password", "BadEncryptedPassword"); err == nil {
+			t.Fatal("expected non-nil err; got nil")
+		}
+		p.record("id", p.max, p.max, "")
+	}
+	if v, _ := p.cache.Get("id"); v.Count != 5 {
+		t.Errorf("expected 5; got %d", v.Count)
+	}
+}
+
+func TestFilterLocked(t *testing.T) {
+	p := New(24*time.Hour, 1, nil)
+	p.Compare("a", "password", "wrongpassword")
+	p.Compare("c", "password", "wrongpassword")
+	locked, unlocked := p.FilterLocked([]any{"a", "b", "c", "d"})
+	if !reflect.DeepEqual(locked, []any{"a", "c"}) {
+		t.Errorf("expected [a c]; got %v", locked)
+	}
+	if !reflect.DeepEqual(unlocked, []any{"b", "d"}) {
+		t.Errorf("expected [b d]; got %v", unlocked)
+	}
+}
+
+func TestInvalidID(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	if err := p.Compare(nil, "password", "password"); err != nil {
+		t.Error(err)
+	}
+	p.SetStrict(true)
+	for _, id := range []any{nil, ""} {
+		if err := p.Compare(id, "password", "password"); err != ErrInvalidID {
+			t.Errorf("expected ErrInvalidID; got %v", err)
+		}
+	}
+	if err := p.Compare("id", "password", "password"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompareTimeout(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password"), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, nil)
+	p.SetCompareTimeout(time.Millisecond)
+	if err := p.CompareHashAndPassword("id", string(hashed), "wrongpassword"); err != ErrComparisonTimeout {
+		t.Errorf("expected ErrComparisonTimeout; got %v", err)
+	}
+	if _, ok := p.cache.Get("id"); ok {
+		t.Error("expected no record; got one")
+	}
+	p.SetCompareTimeout(time.Minute)
+	if err := p.CompareHashAndPassword("id", string(hashed), "password"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompareBefore(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password"), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, nil)
+	if err := p.CompareHashAndPasswordBefore("id", string(hashed), "wrongpassword", time.Now().Add(time.Millisecond)); err != ErrComparisonTimeout {
+		t.Errorf("expected ErrComparisonTimeout; got %v", err)
+	}
+	if err := p.CompareBefore("id", "password", "password", time.Now().Add(-time.Second)); err != ErrComparisonTimeout {
+		t.Errorf("expected ErrComparisonTimeout; got %v", err)
+	}
+	if _, ok := p.cache.Get("id"); ok {
+		t.Error("expected no record; got one")
+	}
+	if err := p.CompareHashAndPasswordBefore("id", string(hashed), "password", time.Now().Add(time.Minute)); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareBefore("id", "password", "password", time.Now().Add(time.Minute)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	p := New(24*time.Hour, 2, nil)
+	p.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	for range 3 {
+		p.Compare("user@example.com", "secret", "wrongpassword")
+	}
+	if n := strings.Count(buf.String(), "exceeded maximum password attempts"); n != 1 {
+		t.Errorf("expected 1 lockout log; got %d", n)
+	}
+	for _, s := range []string{"user@example.com", "secret", "wrongpassword"} {
+		if strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q not logged; got %s", s, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "level=WARN") || !strings.Contains(buf.String(), "attempts=2") {
+		t.Errorf("unexpected log: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "id="+RedactID("user@example.com")) {
+		t.Errorf("expected id logged with RedactID; got %s", buf.String())
+	}
+
+	buf.Reset()
+	p.SetIDRedactor(func(id any) string { return "u***@example.com" })
+	p.Reset("user@example.com")
+	for range 2 {
+		p.Compare("user@example.com", "secret", "wrongpassword")
+	}
+	if !strings.Contains(buf.String(), "id=u***@example.com") {
+		t.Errorf("expected id logged with custom redactor; got %s", buf.String())
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	p := New(24*time.Hour, 1, nil)
+	p.SetAllowlist("service")
+	for range 3 {
+		if err := p.Compare("service", "password", "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("expected ErrIncorrectPassword; got %v", err)
+		}
+	}
+	if p.IsMaxAttempts("service") {
+		t.Error("expected not max attempts; got max")
+	}
+	if err := p.Compare("service", "password", "password"); err != nil {
+		t.Error(err)
+	}
+	p.Compare("user", "password", "wrongpassword")
+	if !p.IsMaxAttempts("user") {
+		t.Error("expected max attempts; got not")
+	}
+}
+
+func TestClientHashed(t *testing.T) {
+	client, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed, err := HashPassword(string(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, nil)
+	p.SetStrict(true)
+	p.SetClientHashed(true)
+	if err := p.CompareHashAndPassword("id", hashed, string(client)); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareHashAndPassword("id", hashed, "password"); err != ErrInvalidClientHash {
+		t.Errorf("expected ErrInvalidClientHash; got %v", err)
+	}
+	if _, ok := p.cache.Get("id"); ok {
+		t.Error("expected no record; got one")
+	}
+}
+
+func TestOpaqueErrors(t *testing.T) {
+	var buf bytes.Buffer
+	p := New(24*time.Hour, 1, nil)
+	p.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	p.SetOpaqueErrors(true)
+	if err := p.Compare("id", "password", "wrongpassword"); err != ErrAuthFailed {
+		t.Errorf("expected ErrAuthFailed; got %v", err)
+	}
+	if err := p.Compare("id", "password", "password"); err != ErrAuthFailed {
+		t.Errorf("expected ErrAuthFailed; got %v", err)
+	}
+	for _, s := range []string{"incorrect password (1)", "exceeded maximum password attempts (1)"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q logged; got %s", s, buf.String())
+		}
+	}
+}
+
+func TestDecrement(t *testing.T) {
+	p := New(24*time.Hour, 3, nil)
+	p.Decrement("id", 1)
+	if _, ok := p.cache.Get("id"); ok {
+		t.Error("expected no record; got one")
+	}
+	for range 3 {
+		p.Compare("id", "password", "wrongpassword")
+	}
+	if !p.IsMaxAttempts("id") {
+		t.Fatal("expected max attempts; got not")
+	}
+	p.Decrement("id", 2)
+	if v, _ := p.cache.Get("id"); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
+	}
+	if p.IsMaxAttempts("id") {
+		t.Error("expected not max attempts; got max")
+	}
+	p.Decrement("id", 5)
+	if v, _ := p.cache.Get("id"); v.Count != 0 {
+		t.Errorf("expected 0; got %d", v.Count)
+	}
+}
+
+func TestConcurrentRecord(t *testing.T) {
+	p := New(24*time.Hour, 10000, nil)
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				p.Compare("id", "password", "wrongpassword")
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := p.cache.Get("id"); v.Count != 2000 {
+		t.Errorf("expected 2000; got %d", v.Count)
+	}
+}
+
+func TestClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	p := New(24*time.Hour, 5, nil)
+	for i := range 100 {
+		p.Compare(i, "password", "wrongpassword")
+	}
+	if n := runtime.NumGoroutine(); n < before+90 {
+		t.Fatalf("expected about %d goroutines; got %d", before+100, n)
+	}
+	p.Close()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expected %d goroutines; got %d", before, n)
+	}
+	if _, ok := p.cache.Get(0); ok {
+		t.Error("expected no record; got one")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	now := time.Now()
+	p := New(time.Hour, 2, nil)
+	p.now = func() time.Time { return now }
+	if s := p.Status("id"); s != (LockoutStatus{Max: 2}) {
+		t.Errorf("unexpected status: %+v", s)
+	}
+	p.Compare("id", "password", "wrongpassword")
+	now = now.Add(10 * time.Minute)
+	if n := p.RemainingAttempts("id"); n != 1 {
+		t.Errorf("expected 1; got %d", n)
+	}
+	p.Compare("id", "password", "wrongpassword")
+	if s := p.Status("id"); s != (LockoutStatus{true, 50 * time.Minute, 2, 2}) {
+		t.Errorf("unexpected status: %+v", s)
+	}
+	if d := p.LockoutRemaining("id"); d != 50*time.Minute {
+		t.Errorf("expected 50m; got %s", d)
+	}
+	now = now.Add(50 * time.Minute)
+	if p.IsMaxAttempts("id") {
+		t.Error("expected not max attempts; got max")
+	}
+	if s := p.Status("id"); s != (LockoutStatus{Max: 2}) {
+		t.Errorf("unexpected status: %+v", s)
+	}
+
+	p.SetRateLimit(2, time.Minute)
+	p.Compare("id", "password", "wrongpassword")
+	now = now.Add(20 * time.Second)
+	p.Compare("id", "password", "wrongpassword")
+	if d := p.LockoutRemaining("id"); d != 40*time.Second {
+		t.Errorf("expected 40s; got %s", d)
+	}
+}
+
+func TestLockoutRetryAfterSeconds(t *testing.T) {
+	now := time.Now()
+	p := New(time.Minute, 1, nil)
+	p.now = func() time.Time { return now }
+	if n := p.LockoutRetryAfterSeconds("id"); n != 0 {
+		t.Errorf("expected 0; got %d", n)
+	}
+	p.Compare("id", "password", "wrongpassword")
+	if n := p.LockoutRetryAfterSeconds("id"); n != 60 {
+		t.Errorf("expected 60; got %d", n)
+	}
+	now = now.Add(59*time.Second + 500*time.Millisecond)
+	if n := p.LockoutRetryAfterSeconds("id"); n != 1 {
+		t.Errorf("expected 1; got %d", n)
+	}
+	now = now.Add(time.Second)
+	if n := p.LockoutRetryAfterSeconds("id"); n != 0 {
+		t.Errorf("expected 0; got %d", n)
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetNormalizer(strings.ToLower)
+	hash, err := p.HashPassword("PassWord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPassword("", hash, "PASSWORD"); err != nil {
+		t.Error(err)
+	}
+	if err := p.Compare("", "Password", "pASSWORD"); err != nil {
+		t.Error(err)
+	}
+	p.SetNormalizer(nil)
+	if err := p.CompareHashAndPassword("", hash, "PASSWORD"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+}
+
+func TestRejectLongPasswords(t *testing.T) {
+	long := strings.Repeat("a", 80)
+	p := New(24*time.Hour, 5, nil)
+	if _, err := p.HashPassword(long); err != ErrPasswordTooLong {
+		t.Errorf("expected ErrPasswordTooLong; got %v", err)
+	}
+	hash, err := p.HashPassword(long[:72])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPassword("", hash, long); err != nil {
+		t.Error(err)
+	}
+	p.SetRejectLongPasswords(true)
+	if err := p.CompareHashAndPassword("", hash, long); err != ErrPasswordTooLong {
+		t.Errorf("expected ErrPasswordTooLong; got %v", err)
+	}
+	if err := p.CompareHashAndPassword("", "$2$"+hash[4:], long); err != ErrPasswordTooLong {
+		t.Errorf("$2$: expected ErrPasswordTooLong; got %v", err)
+	}
+	if err := p.CompareHashAndPassword("", hash, long[:72]); err != nil {
+		t.Error(err)
+	}
+	p.SetPepper([]byte("pepper"))
+	if hash, err = p.HashPassword(long); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPassword("", hash, long); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompareWith(t *testing.T) {
+	verifier := func(hash, password string) error {
+		if hash != "hash:"+password {
+			return ErrIncorrectPassword
+		}
+		return nil
+	}
+	p := New(24*time.Hour, 5, nil)
+	if err := p.CompareWith("", "hash:password", "password", verifier); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareWith("", "hash:password", "wrongpassword", verifier); err != (incorrectPasswordError{1, ErrIncorrectPassword}) {
+		t.Errorf("expected incorrect password 1; got %v", err)
+	}
+	errVerifier := errors.New("verifier error")
+	if err := p.CompareWith("", "hash:password", "password", func(string, string) error { return errVerifier }); err != errVerifier {
+		t.Errorf("expected verifier error; got %v", err)
+	}
+	if v, _ := p.cache.Get(""); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
+	}
+	if err := p.CompareWith("", "", "password", compareArgon2); !errors.Is(err, ErrInvalidArgon2Hash) {
+		t.Errorf("expected ErrInvalidArgon2Hash; got %v", err)
+	}
+}
+
+func TestRequireUTF8(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, []byte{0xff, 0xfe, 0xfd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := base64.StdEncoding.EncodeToString(ciphertext)
+	p := New(24*time.Hour, 5, priv)
+	if _, err := p.DecryptPKCS1v15(encrypted); err != nil {
+		t.Errorf("expected nil error; got %v", err)
+	}
+	p.SetRequireUTF8(true)
+	if _, err := p.DecryptPKCS1v15(encrypted); err != ErrInvalidPlaintext {
+		t.Errorf("expected ErrInvalidPlaintext; got %v", err)
+	}
+	if err := p.Compare("id", "password", encrypted); err != ErrInvalidPlaintext {
+		t.Errorf("expected ErrInvalidPlaintext; got %v", err)
+	}
+	if !p.IsMaxAttempts("id") {
+		t.Error("expected max attempts; got not")
+	}
+}
+
+func TestFailureWindowStart(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	if _, ok := p.FailureWindowStart("id"); ok {
+		t.Error("expected no failure window; got one")
+	}
+	start := now
+	p.Compare("id", "password", "wrongpassword")
+	now = now.Add(10 * time.Second)
+	p.Compare("id", "password", "wrongpassword")
+	if first, ok := p.FailureWindowStart("id"); !ok || !first.Equal(start) {
+		t.Errorf("expected failure window start %s; got %s, %v", start, first, ok)
+	}
+	p.Reset("id")
+	if _, ok := p.FailureWindowStart("id"); ok {
+		t.Error("expected no failure window; got one")
+	}
+	p.Compare("id", "password", "wrongpassword")
+	if first, ok := p.FailureWindowStart("id"); !ok || !first.Equal(now) {
+		t.Errorf("expected failure window start %s; got %s, %v", now, first, ok)
+	}
+}
+
+func TestHashPasswordBytes(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	hash, err := p.HashPasswordBytes("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte("password")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashBytesAndPassword("id", hash, "password"); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareHashBytesAndPassword("id", hash, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+}
+
+func TestMinResponseTime(t *testing.T) {
+	p := New(24*time.Hour, 1, nil)
+	p.SetMinResponseTime(50 * time.Millisecond)
+	for i, password := range []string{"password", "wrongpassword", "password"} {
+		start := time.Now()
+		p.Compare("id", "password", password)
+		if d := time.Since(start); d < 50*time.Millisecond {
+			t.Errorf("#%d: expected at least 50ms; got %s", i, d)
+		}
+	}
+	if !p.IsMaxAttempts("id") {
+		t.Error("expected max attempts; got not")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetKeepLastSuccess(time.Hour)
+	p.Compare("a", "password", "wrongpassword")
+	p.Compare("b", "password", "wrongpassword")
+	p.Compare("b", "password", "wrongpassword")
+	p.Compare("c", "password", "password")
+	m := p.Snapshot()
+	if expected := map[any]int{"a": 1, "b": 2}; !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %v; got %v", expected, m)
+	}
+	m["a"] = 5
+	if p.IsMaxAttempts("a") {
+		t.Error("expected snapshot to be a copy")
+	}
+}
+
+func TestOnLock(t *testing.T) {
+	p := New(24*time.Hour, 3, nil)
+	var mu sync.Mutex
+	var locked []any
+	p.SetOnLock(func(id any) {
+		mu.Lock()
+		defer mu.Unlock()
+		locked = append(locked, id)
+	})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Compare("id", "password", "wrongpassword")
+		}()
+	}
+	wg.Wait()
+	if !reflect.DeepEqual(locked, []any{"id"}) {
+		t.Errorf("expected [id]; got %v", locked)
+	}
+	p.Decrement("id", 1)
+	p.Compare("id", "password", "wrongpassword")
+	if len(locked) != 2 {
+		t.Errorf("expected 2 lock notifications; got %d", len(locked))
+	}
+}
+
+func TestPlaintextFallback(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := base64.StdEncoding.EncodeToString(ciphertext)
+	p := New(24*time.Hour, 5, priv)
+	if err := p.Compare("id", "password", "password"); err == nil || errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected decryption error; got %v", err)
+	}
+	p.Reset("id")
+	p.SetPlaintextFallback(true)
+	if err := p.Compare("id", "password", encrypted); err != nil {
+		t.Error(err)
+	}
+	if err := p.Compare("id", "password", "password"); err != nil {
+		t.Error(err)
+	}
+	if err := p.Compare("id", "password", "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	ciphertext[0] ^= 0xff
+	if err := p.Compare("id", "password", base64.StdEncoding.EncodeToString(ciphertext)); err == nil || errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected decryption error; got %v", err)
+	}
+	if !p.IsMaxAttempts("id") {
+		t.Error("expected max attempts; got not")
+	}
+}
+
+func TestCompareWithKey(t *testing.T) {
+	priv1, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv2, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &priv2.PublicKey, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := base64.StdEncoding.EncodeToString(ciphertext)
+	p := New(24*time.Hour, 5, priv1)
+	if err := p.CompareWithKey("id", "password", encrypted, priv2); err != nil {
+		t.Error(err)
+	}
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPasswordWithKey("id", hash, encrypted, priv2); err != nil {
+		t.Error(err)
+	}
+	if err := p.Compare("id", "password", encrypted); err == nil {
+		t.Error("expected decryption error with the configured key; got nil")
+	}
+}
+
+func TestCompareAndResetOther(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Compare("challenge", "code", "wrongcode")
+	if err := p.CompareAndResetOther("id", hash, "wrongpassword", "challenge"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if _, ok := p.Attempts("challenge"); !ok {
+		t.Error("expected challenge attempts kept on failure")
+	}
+	if err := p.CompareAndResetOther("id", hash, "password", "challenge"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Attempts("challenge"); ok {
+		t.Error("expected challenge attempts reset on success")
+	}
+}
+
+func TestCompareHashAndPasswordWithDisplay(t *testing.T) {
+	p := New(24*time.Hour, 3, nil)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, device := range []string{"phone", "laptop", "laptop"} {
+		if err := p.CompareHashAndPasswordWithDisplay("user", device, hash, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("expected ErrIncorrectPassword; got %v", err)
+		}
+	}
+	if n := p.DisplayAttempts("phone"); n != 1 {
+		t.Errorf("expected 1 phone attempt; got %d", n)
+	}
+	if n := p.DisplayAttempts("laptop"); n != 2 {
+		t.Errorf("expected 2 laptop attempts; got %d", n)
+	}
+	if !p.IsMaxAttempts("user") {
+		t.Error("expected user locked out")
+	}
+	if p.IsMaxAttempts("laptop") {
+		t.Error("expected display id not locked out")
+	}
+	if err := p.CompareHashAndPasswordWithDisplay("user", "phone", hash, "password"); !errors.Is(err, ErrMaxPasswordAttempts) {
+		t.Errorf("expected ErrMaxPasswordAttempts; got %v", err)
+	}
+	if n := p.DisplayAttempts("phone"); n != 1 {
+		t.Errorf("expected lockout not counted; got %d", n)
+	}
+	p.Reset("user")
+	if err := p.CompareHashAndPasswordWithDisplay("user", "phone", hash, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.DisplayAttempts("phone"); n != 0 {
+		t.Errorf("expected phone attempts reset on success; got %d", n)
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetTrimSpace(true)
+	hash, err := p.HashPassword("password ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range []string{"password", " password", "password\t"} {
+		if err := p.CompareHashAndPassword("id", hash, password); err != nil {
+			t.Errorf("%q: %v", password, err)
+		}
+	}
+	if err := p.Compare("id", "password", "password "); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareHashAndPassword("id", hash, "pass word"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+}
+
+func TestBcryptErrorChain(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.CompareHashAndPassword("id", hash, "wrongpassword")
+	if !errors.Is(err, ErrIncorrectPassword) || errors.Unwrap(err) != bcrypt.ErrMismatchedHashAndPassword {
+		t.Errorf("expected ErrIncorrectPassword caused by bcrypt mismatch; got %v", err)
+	}
+	err = p.CompareHashAndPassword("id", "$2a$04$short", "password")
+	if !errors.Is(err, ErrInvalidHash) || errors.Unwrap(err) != bcrypt.ErrHashTooShort {
+		t.Errorf("expected ErrInvalidHash caused by bcrypt.ErrHashTooShort; got %v", err)
+	}
+}
+
+func TestNoPasswordSet(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	if err := p.CompareHashAndPassword("id", "", "password"); err != ErrNoPasswordSet {
+		t.Errorf("expected ErrNoPasswordSet; got %v", err)
+	}
+	if err := p.CompareHashBytesAndPassword("id", nil, "password"); err != ErrNoPasswordSet {
+		t.Errorf("expected ErrNoPasswordSet; got %v", err)
+	}
+	if _, ok := p.Attempts("id"); ok {
+		t.Error("expected no attempt recorded")
+	}
+}
+
+func TestCompareHashAndPasswordPlain(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, priv)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPasswordPlain("id", hash, "password"); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareHashAndPasswordPlain("id", hash, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if err := p.CompareHashAndPassword("id", hash, "password"); err == nil || errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected decryption error; got %v", err)
+	}
+}
+
+func TestMaxAttemptsError(t *testing.T) {
+	p := New(time.Minute, 1, nil)
+	p.Compare("id", "password", "wrongpassword")
+	err := p.Compare("id", "password", "password")
+	var e MaxAttemptsError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected MaxAttemptsError; got %v", err)
+	}
+	if !errors.Is(err, ErrMaxPasswordAttempts) {
+		t.Error("expected ErrMaxPasswordAttempts")
+	}
+	if e.Max != 1 || e.RetryAfter <= 0 || e.RetryAfter > time.Minute {
+		t.Errorf("unexpected error fields: %+v", e)
+	}
+	p.SetSync("a", make(chanTransport, 10))
+	p.ApplyUpdate(SyncUpdate{Node: "b", ID: "remote", Count: 1, Expires: time.Now().Add(time.Minute)})
+	if err := p.Compare("remote", "password", "password"); !errors.As(err, &e) || e.RetryAfter <= 0 {
+		t.Errorf("expected MaxAttemptsError with remaining lockout of remote attempts; got %v", err)
+	}
+}
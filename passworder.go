@@ -2,86 +2,548 @@ package password
 
 import (
 	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log/slog"
+	"strings"
 	"time"
+	"unicode/utf8"
 
-	"github.com/sunshineplan/utils/cache"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Passworder struct {
-	cache *cache.Cache[any, int]
-	dur   time.Duration
-	max   int
-	key   *rsa.PrivateKey
+	*AttemptLimiter
+
+	key *rsa.PrivateKey
+	enc Encoding
+
+	strict bool
+
+	timeout time.Duration
+	minTime time.Duration
+
+	peppers      [][]byte
+	pepperHashes []func() hash.Hash
+	prefixPepper string
+
+	clientHashed bool
+
+	cost     int
+	adaptive adaptiveCost
+	argon2   *Argon2Params
+	policy   Policy
+	opaque   bool
+
+	maxVerifyCost int
+
+	normalizer func(string) string
+	trim       bool
+	rejectLong bool
+	utf8       bool
+	fallback   bool
+	clientMAC  []byte
+
+	noDecryptLock bool
+	keepOnChange  bool
+
+	prefixes []string
+
+	results *resultCache
+
+	display *AttemptLimiter
+
+	minInterval time.Duration
+	attempted   *attemptTimes
+
+	token *tokenConfig
+
+	dummy dummyHash
+
+	maxHashed int
+	maxPlain  int
+
+	version int
+
+	captcha int
 }
 
 func New(d time.Duration, n int, key *rsa.PrivateKey) *Passworder {
-	return &Passworder{cache.New[any, int](true), d, n, key}
+	return &Passworder{
+		AttemptLimiter: NewAttemptLimiter(d, n),
+		key:            key,
+		enc:            StdEncoding,
+		cost:           bcrypt.MinCost,
+		display:        NewAttemptLimiter(d, 0),
+	}
 }
 
-func (p *Passworder) SetDuration(d time.Duration) { p.dur = d }
-func (p *Passworder) SetMaxAttempts(n int)        { p.max = n }
-func (p *Passworder) SetKey(key *rsa.PrivateKey)  { p.key = key }
+func (p *Passworder) SetKey(key *rsa.PrivateKey) { p.key = key }
+
+// SetCost sets the bcrypt cost used by HashPassword, see CalibrateCost.
+func (p *Passworder) SetCost(cost int) { p.cost = cost }
 
-func (p *Passworder) record(id any, n int) int {
-	if v, ok := p.cache.Get(id); ok {
-		n += v
+// SetEncoding sets the encoding of ciphertext, nil means StdEncoding. It applies only
+// to RSA ciphertext and its SetClientHMACKey signature: the other formats keep their
+// fixed encodings, such as the raw base64 of pepper digests so that existing hashes
+// still verify, and the base64url of password tokens required by JWT.
+func (p *Passworder) SetEncoding(enc Encoding) {
+	if enc == nil {
+		enc = StdEncoding
 	}
-	p.cache.Set(id, n, p.dur, nil)
-	return n
+	p.enc = enc
 }
 
-func (p *Passworder) recordIncorrect(id any) error {
-	return incorrectPasswordError(p.record(id, 1))
+// SetStrict enables strict mode, which detects caller mistakes with heuristics
+// that are too costly or too opinionated for production use: a nil or empty id
+// is rejected with ErrInvalidID, and swapped hash and password arguments are
+// reported with ErrArgumentsSwapped.
+func (p *Passworder) SetStrict(strict bool) { p.strict = strict }
+
+// SetCompareTimeout limits the wall-clock time of a hash comparison, ErrComparisonTimeout
+// is returned when it is exceeded and the attempt is not counted. Zero d means no limit.
+func (p *Passworder) SetCompareTimeout(d time.Duration) { p.timeout = d }
+
+// SetClientHashed declares that clients bcrypt the password before sending it, so the
+// stored hash is the server-side bcrypt of the client-side bcrypt hash. In this mode
+// CompareHashAndPassword requires the (decrypted) password to be a bcrypt hash,
+// returning ErrInvalidClientHash without counting an attempt otherwise, and uses it
+// as is for the outer comparison. Hash such passwords with HashPassword as usual.
+func (p *Passworder) SetClientHashed(clientHashed bool) { p.clientHashed = clientHashed }
+
+// SetOpaqueErrors makes comparisons return ErrAuthFailed for both incorrect password
+// and exceeded maximum password attempts, hiding from clients whether the id has been
+// locked out. The precise error is still logged at debug level, see SetLogger.
+func (p *Passworder) SetOpaqueErrors(opaque bool) { p.opaque = opaque }
+
+// SetNormalizer sets a function applied to passwords before hashing and comparison,
+// e.g. strings.ToLower or NFKC normalization, to reproduce a legacy scheme during
+// migration. nil means identity. Normalizing shrinks the password space: lowercasing
+// makes "Password" and "PASSWORD" the same password and weakens every password.
+func (p *Passworder) SetNormalizer(fn func(string) string) { p.normalizer = fn }
+
+// SetTrimSpace trims leading and trailing white space from passwords before hashing
+// and comparison, so that a space added by a client does not fail the comparison.
+// Like normalizing, it slightly shrinks the password space, and hashes already made
+// of passwords with such spaces no longer verify.
+func (p *Passworder) SetTrimSpace(trim bool) { p.trim = trim }
+
+func (p *Passworder) normalize(password string) string {
+	if p.trim {
+		password = strings.TrimSpace(password)
+	}
+	if p.normalizer == nil {
+		return password
+	}
+	return p.normalizer(password)
 }
 
-func (p *Passworder) IsMaxAttempts(id any) bool {
-	v, ok := p.cache.Get(id)
-	return ok && v >= p.max
+// SetRejectLongPasswords makes hash comparisons return ErrPasswordTooLong when the
+// password given to bcrypt exceeds 72 bytes, instead of only comparing its first 72
+// bytes. HashPassword always rejects such passwords.
+func (p *Passworder) SetRejectLongPasswords(reject bool) { p.rejectLong = reject }
+
+func (p *Passworder) recordIncorrect(id any, max int, source string, cause error) error {
+	return incorrectPasswordError{p.record(id, 1, max, source), cause}
 }
 
-func (p *Passworder) Reset(id any) {
-	p.cache.Delete(id)
+// SetMaxAttemptsHashed sets the maximum password attempts of hash comparisons, such as
+// CompareHashAndPassword, and SetMaxAttemptsPlain the one of plaintext comparisons,
+// such as Compare, e.g. to allow more attempts at API keys than at user passwords. An
+// id's failures are counted together whichever comparison is used, only the threshold
+// differs. Zero n means the maximum set by SetMaxAttempts.
+//
+// The methods of the embedded AttemptLimiter, such as IsMaxAttempts, Status and
+// WouldLockNext, report against the maximum set by SetMaxAttempts: use StatusHashed
+// and StatusPlain for the thresholds the comparisons enforce.
+func (p *Passworder) SetMaxAttemptsHashed(n int) { p.maxHashed = n }
+
+// SetMaxAttemptsPlain sets the maximum password attempts of plaintext comparisons,
+// see SetMaxAttemptsHashed.
+func (p *Passworder) SetMaxAttemptsPlain(n int) { p.maxPlain = n }
+
+// StatusHashed returns id's lockout status against the maximum attempts of hash
+// comparisons, see SetMaxAttemptsHashed.
+func (p *Passworder) StatusHashed(id any) LockoutStatus { return p.status(id, p.attemptMax(true)) }
+
+// StatusPlain returns id's lockout status against the maximum attempts of plaintext
+// comparisons, see SetMaxAttemptsPlain.
+func (p *Passworder) StatusPlain(id any) LockoutStatus { return p.status(id, p.attemptMax(false)) }
+
+// attemptMax returns the maximum password attempts of a hash or plaintext comparison.
+func (p *Passworder) attemptMax(hash bool) int {
+	if hash && p.maxHashed > 0 {
+		return p.maxHashed
+	} else if !hash && p.maxPlain > 0 {
+		return p.maxPlain
+	}
+	return p.max
+}
+
+// SetRequireUTF8 makes decryption return ErrInvalidPlaintext when the plaintext is not
+// valid UTF-8, which usually means the ciphertext was encrypted with another key.
+func (p *Passworder) SetRequireUTF8(require bool) { p.utf8 = require }
+
+// SetLockOnDecryptError sets whether a password which fails to decrypt, or whose
+// payload fails to verify with SetClientHMACKey, locks the id out at once, which is
+// the default since it is more likely a forged payload than a typo. Disabling it
+// returns the error without counting an attempt for malformed payloads only, those
+// failing with ErrInvalidCiphertextEncoding, so that a client encoding bug does not
+// lock its users out. Well-formed payloads which fail to decrypt or verify still lock
+// the id out, so that they cannot be used as a padding oracle.
+func (p *Passworder) SetLockOnDecryptError(lock bool) { p.noDecryptLock = !lock }
+
+// decryptFailed locks id out after a decryption or payload verification failure,
+// unless disabled for malformed payloads.
+func (p *Passworder) decryptFailed(id any, limit int, source string, err error) {
+	if p.noDecryptLock && errors.Is(err, ErrInvalidCiphertextEncoding) {
+		return
+	}
+	p.record(id, limit, limit, source)
+}
+
+// SetPlaintextFallback accepts plaintext passwords as well as encrypted ones while
+// clients migrate to encryption. Only input which cannot be a ciphertext for the key,
+// i.e. does not decode to exactly the key size, is taken as plaintext; a ciphertext
+// which fails to decrypt is still a decryption failure.
+func (p *Passworder) SetPlaintextFallback(fallback bool) { p.fallback = fallback }
+
+func (p *Passworder) isCiphertext(priv *rsa.PrivateKey, s string) bool {
+	b, err := p.enc.DecodeString(s)
+	return err == nil && len(b) == priv.Size()
 }
 
 func (p *Passworder) DecryptPKCS1v15(s string) (string, error) {
-	return DecryptPKCS1v15(p.key, s)
+	return p.decrypt(p.key, s)
+}
+
+// SelfTest checks that the configured key is set, valid and decrypts what its public
+// key encrypts with the configured encoding, e.g. to fail fast at startup instead of
+// on the first login.
+func (p *Passworder) SelfTest() error {
+	if p.key == nil {
+		return errors.New("no private key")
+	}
+	if err := p.key.Validate(); err != nil {
+		return err
+	}
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(b)
+	ciphertext, err := rsa.EncryptPKCS1v15(random, &p.key.PublicKey, []byte(nonce))
+	if err != nil {
+		return err
+	}
+	plain, err := p.DecryptPKCS1v15(p.enc.EncodeToString(ciphertext))
+	if err != nil {
+		return err
+	}
+	if plain != nonce {
+		return errors.New("decrypted self test payload mismatch")
+	}
+	return nil
+}
+
+func (p *Passworder) decrypt(priv *rsa.PrivateKey, s string) (string, error) {
+	plain, err := DecryptPKCS1v15WithEncoding(priv, s, p.enc)
+	if err != nil {
+		return "", err
+	}
+	if p.utf8 && !utf8.ValidString(plain) {
+		return "", ErrInvalidPlaintext
+	}
+	return plain, nil
+}
+
+func (p *Passworder) withTimeout(fn func() error) error {
+	if p.timeout <= 0 {
+		return fn()
+	}
+	return withDeadline(time.Now().Add(p.timeout), fn)
+}
+
+// withDeadline returns ErrComparisonTimeout if fn does not return before deadline,
+// leaving it to finish in the background. Zero deadline means no limit.
+func withDeadline(deadline time.Time, fn func() error) error {
+	if deadline.IsZero() {
+		return fn()
+	}
+	c := make(chan error, 1)
+	go func() { c <- fn() }()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case err := <-c:
+		return err
+	case <-timer.C:
+		return ErrComparisonTimeout
+	}
+}
+
+// SetMinResponseTime pads each comparison to last at least d, so that locked,
+// incorrect and successful comparisons cannot be told apart by their duration.
+// d should exceed the duration of a hash comparison. Zero d disables it.
+func (p *Passworder) SetMinResponseTime(d time.Duration) { p.minTime = d }
+
+// pad sleeps until minTime has elapsed since start, measured on the monotonic clock.
+func (p *Passworder) pad(start time.Time) {
+	if d := p.minTime - time.Since(start); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+type compareOptions struct {
+	hash      bool   // key is a hash
+	source    string // recorded on failure
+	prehashed bool   // password is already peppered
+
+	priv  *rsa.PrivateKey // overrides the configured key
+	plain bool            // password is already decrypted
+
+	verifier func(hash, password string) error
+	expires  time.Time // expiry the password is bound to, see HashPasswordExpiring
+	deadline time.Time // of the whole comparison
+
+	display    any // also counted by the display counter
+	hasDisplay bool
+
+	captchaSolved bool
 }
 
-func (p *Passworder) compare(id any, key, password string, hash bool) (string, error) {
-	if p.IsMaxAttempts(id) {
-		return "", maxPasswordAttemptsError(p.max)
+type compareResult struct {
+	password string
+	rehash   bool
+}
+
+func (p *Passworder) compare(id any, key, password string, opt compareOptions) (res compareResult, err error) {
+	if p.minTime > 0 {
+		defer p.pad(time.Now())
+	}
+	if p.opaque {
+		defer func() {
+			if errors.Is(err, ErrIncorrectPassword) || errors.Is(err, ErrMaxPasswordAttempts) {
+				p.log(slog.LevelDebug, "authentication failed", id, slog.String("error", err.Error()))
+				err = ErrAuthFailed
+			}
+		}()
+	}
+	var cached bool
+	if opt.hasDisplay {
+		// A result served from the cache was already counted.
+		defer func() {
+			if !cached {
+				p.countDisplay(opt.display, err)
+			}
+		}()
+	}
+	if !validID(id) || p.strict && (id == nil || id == "") {
+		return res, ErrInvalidID
+	}
+	if opt.hash && opt.verifier == nil && key == "" {
+		return res, ErrNoPasswordSet
+	}
+	limit := p.attemptMax(opt.hash)
+	// The decision and the error are made from one snapshot, so that a locked error
+	// always carries the remaining lockout.
+	if s := p.status(id, limit); s.Locked {
+		return res, MaxAttemptsError{Max: limit, RetryAfter: s.RetryAfter}
+	}
+	if p.captcha > 0 && !opt.captchaSolved {
+		if v, _ := p.get(id); v.Count+p.remoteCount(id) >= p.captcha {
+			return res, ErrCaptchaRequired
+		}
+	}
+	if p.minInterval > 0 && !p.admit(id) {
+		return res, ErrTooFast
+	}
+	if !opt.deadline.IsZero() && !time.Now().Before(opt.deadline) {
+		return res, ErrComparisonTimeout
 	}
-	var err error
-	if p.key != nil {
-		password, err = p.DecryptPKCS1v15(password)
+	if opt.hash && p.strict && !p.clientHashed && !IsValidHash(key) && IsValidHash(password) {
+		return res, ErrArgumentsSwapped
+	}
+	priv := p.key
+	if opt.priv != nil {
+		priv = opt.priv
+	} else if opt.plain {
+		priv = nil
+	}
+	if c := p.results; c != nil && opt.hash && opt.verifier == nil {
+		k := c.key(id, key, password)
+		k.priv, k.prehashed, k.clientHashed, k.expires = priv, opt.prehashed, p.clientHashed, opt.expires
+		if v, ok := c.get(k); ok {
+			cached = true
+			return v.res, v.err
+		}
+		defer func() {
+			if err == nil || errors.Is(err, ErrIncorrectPassword) {
+				c.set(k, res, err)
+			}
+		}()
+	}
+	if p.token != nil {
+		if password, err = p.parseToken(password); err != nil {
+			p.log(slog.LevelDebug, "invalid password token", id, slog.String("error", err.Error()))
+			return res, err
+		}
+	}
+	if priv != nil && p.clientMAC != nil {
+		if password, err = p.verifyPayload(password); err != nil {
+			p.log(slog.LevelDebug, "tampered password payload", id)
+			p.decryptFailed(id, limit, opt.source, err)
+			return res, err
+		}
+	}
+	if priv != nil && (!p.fallback || p.isCiphertext(priv, password)) {
+		password, err = p.decrypt(priv, password)
 		if err != nil {
-			p.record(id, p.max)
-			return "", err
+			p.log(slog.LevelDebug, "failed to decrypt password", id, slog.String("error", err.Error()))
+			p.decryptFailed(id, limit, opt.source, err)
+			return res, err
 		}
 	}
-	if hash {
-		if err = bcrypt.CompareHashAndPassword([]byte(key), []byte(password)); err != nil {
+	password = p.normalize(password)
+	if !opt.expires.IsZero() {
+		password = bindExpiry(opt.expires, password)
+	}
+	if opt.hash && p.clientHashed {
+		if _, err = bcrypt.Cost([]byte(password)); err != nil {
+			return res, ErrInvalidClientHash
+		}
+	}
+	if opt.verifier != nil {
+		if err = p.withTimeout(func() error { return opt.verifier(key, password) }); err != nil {
+			if errors.Is(err, ErrIncorrectPassword) || err == bcrypt.ErrMismatchedHashAndPassword {
+				return res, p.recordIncorrect(id, limit, opt.source, err)
+			}
+			return res, err
+		}
+	} else if opt.hash {
+		var rehash bool
+		if err = withDeadline(opt.deadline, func() (err error) {
+			rehash, err = p.verify(key, password, opt.prehashed)
+			return
+		}); err != nil {
 			if err == bcrypt.ErrMismatchedHashAndPassword {
-				return "", p.recordIncorrect(id)
+				return res, p.recordIncorrect(id, limit, opt.source, err)
 			}
-			return "", err
+			return res, err
 		}
+		res.rehash = rehash
 	} else {
-		if key != password {
-			return "", p.recordIncorrect(id)
+		if p.normalize(key) != password {
+			return res, p.recordIncorrect(id, limit, opt.source, nil)
 		}
 	}
-	p.Reset(id)
-	return password, nil
+	p.succeed(id)
+	res.password = password
+	return res, nil
 }
 
 func (p *Passworder) Compare(id any, key, password string) error {
-	_, err := p.compare(id, key, password, false)
+	_, err := p.compare(id, key, password, compareOptions{})
 	return err
 }
 
 func (p *Passworder) CompareHashAndPassword(id any, hash, password string) error {
-	_, err := p.compare(id, hash, password, true)
+	_, err := p.compare(id, hash, password, compareOptions{hash: true})
+	return err
+}
+
+// CompareHashAndPasswordPlain is like CompareHashAndPassword, but password is already
+// decrypted, e.g. kept from a previous attempt, so it is not decrypted with the key.
+func (p *Passworder) CompareHashAndPasswordPlain(id any, hash, plaintext string) error {
+	_, err := p.compare(id, hash, plaintext, compareOptions{hash: true, plain: true})
+	return err
+}
+
+// CompareAndResetOther is like CompareHashAndPassword, but on success it also resets
+// otherID, e.g. the step-up challenge counter of the session verifying its password.
+func (p *Passworder) CompareAndResetOther(id any, hash, password string, otherID any) error {
+	if _, err := p.compare(id, hash, password, compareOptions{hash: true}); err != nil {
+		return err
+	}
+	p.Reset(otherID)
+	return nil
+}
+
+// CompareHashBytesAndPassword is like CompareHashAndPassword, but hash is given as bytes,
+// e.g. as returned by HashPasswordBytes.
+func (p *Passworder) CompareHashBytesAndPassword(id any, hash []byte, password string) error {
+	return p.CompareHashAndPassword(id, string(hash), password)
+}
+
+// CompareWithKey is like Compare, but decrypts password with priv instead of the
+// configured key, e.g. with the key of the tenant id belongs to.
+func (p *Passworder) CompareWithKey(id any, key, password string, priv *rsa.PrivateKey) error {
+	_, err := p.compare(id, key, password, compareOptions{priv: priv})
+	return err
+}
+
+// CompareHashAndPasswordWithKey is like CompareHashAndPassword, but decrypts password
+// with priv instead of the configured key.
+func (p *Passworder) CompareHashAndPasswordWithKey(id any, hash, password string, priv *rsa.PrivateKey) error {
+	_, err := p.compare(id, hash, password, compareOptions{hash: true, priv: priv})
+	return err
+}
+
+// CompareBefore is like Compare, but returns ErrComparisonTimeout without counting an
+// attempt if deadline has passed.
+func (p *Passworder) CompareBefore(id any, key, password string, deadline time.Time) error {
+	_, err := p.compare(id, key, password, compareOptions{deadline: deadline})
+	return err
+}
+
+// CompareHashAndPasswordBefore is like CompareHashAndPassword, but returns
+// ErrComparisonTimeout without counting an attempt if the hash comparison does not
+// complete before deadline, in addition to the timeout set by SetCompareTimeout.
+func (p *Passworder) CompareHashAndPasswordBefore(id any, hash, password string, deadline time.Time) error {
+	_, err := p.compare(id, hash, password, compareOptions{hash: true, deadline: deadline})
+	return err
+}
+
+// SetCaptchaThreshold makes comparisons return ErrCaptchaRequired, without comparing
+// or counting an attempt, once id has n or more failed attempts, so that the client
+// can be asked to solve a CAPTCHA before the lockout is reached. Once it is solved,
+// compare with CompareWithCaptchaSolved or CompareHashAndPasswordWithCaptchaSolved,
+// which still count failures towards the lockout. Zero n disables it.
+func (p *Passworder) SetCaptchaThreshold(n int) { p.captcha = n }
+
+// CompareWithCaptchaSolved is like Compare, for a client which has solved a CAPTCHA.
+func (p *Passworder) CompareWithCaptchaSolved(id any, key, password string) error {
+	_, err := p.compare(id, key, password, compareOptions{captchaSolved: true})
+	return err
+}
+
+// CompareHashAndPasswordWithCaptchaSolved is like CompareHashAndPassword, for a client
+// which has solved a CAPTCHA.
+func (p *Passworder) CompareHashAndPasswordWithCaptchaSolved(id any, hash, password string) error {
+	_, err := p.compare(id, hash, password, compareOptions{hash: true, captchaSolved: true})
+	return err
+}
+
+// CompareWithMeta is like Compare, source (e.g. client address) is recorded on failure.
+func (p *Passworder) CompareWithMeta(id any, key, password, source string) error {
+	_, err := p.compare(id, key, password, compareOptions{source: source})
+	return err
+}
+
+// CompareHashAndPasswordWithMeta is like CompareHashAndPassword, source is recorded on failure.
+func (p *Passworder) CompareHashAndPasswordWithMeta(id any, hash, password, source string) error {
+	_, err := p.compare(id, hash, password, compareOptions{hash: true, source: source})
+	return err
+}
+
+// CompareWith is like CompareHashAndPassword, but hash is verified by verifier, which
+// lets the caller choose the algorithm per call while keeping the lockout accounting.
+// verifier must return ErrIncorrectPassword or bcrypt.ErrMismatchedHashAndPassword on
+// mismatch, other errors are returned as is without counting an attempt. No pepper
+// is applied to the password.
+func (p *Passworder) CompareWith(id any, hash, password string, verifier func(hash, password string) error) error {
+	_, err := p.compare(id, hash, password, compareOptions{hash: true, verifier: verifier})
 	return err
 }
@@ -0,0 +1,220 @@
+package password
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SetPepper sets the secret pepper. When set, passwords are HMAC-SHA256 keyed by
+// the pepper before being hashed or compared with a hash. The pepper should be
+// kept outside the database which stores the hashes.
+func (p *Passworder) SetPepper(pepper []byte) {
+	p.peppers = [][]byte{pepper}
+}
+
+// RotatePepper replaces the pepper with newPepper, the previous pepper (or no pepper)
+// is still accepted for verification during the transition, and CompareAndUpgrade
+// reports hashes made with it so that they can be rehashed.
+func (p *Passworder) RotatePepper(newPepper []byte) {
+	var old []byte
+	if len(p.peppers) > 0 {
+		old = p.peppers[0]
+	}
+	p.peppers = [][]byte{newPepper, old}
+}
+
+// SetPeppers sets an ordered list of peppers, the first one is used for new hashes
+// and all of them are tried for verification, CompareAndUpgrade reports hashes made
+// with any but the first so that they can be rehashed. A nil entry stands for no pepper,
+// which allows hashes made before a pepper was introduced to keep working.
+func (p *Passworder) SetPeppers(peppers [][]byte) {
+	p.peppers = append([][]byte(nil), peppers...)
+}
+
+// SetPrefixPepper sets a secret prepended as is to passwords before hashing and
+// comparison, as legacy schemes computing bcrypt(pepper + password) do, so that their
+// hashes can be verified and migrated. Prefer SetPepper for new deployments: the
+// prefix counts towards the 72 bytes bcrypt uses, leaving less of the password
+// hashed, whereas an HMAC pepper keeps the whole password and a fixed length input
+// whatever the pepper. A prefix pepper is applied before the HMAC pepper if both are set.
+func (p *Passworder) SetPrefixPepper(pepper string) { p.prefixPepper = pepper }
+
+// SetPepperHash sets the hash function of the pepper HMAC, e.g. sha512.New to match an
+// existing scheme, nil means sha256.New. All the previous functions are still accepted
+// for verification during the transition, most recent first, and CompareAndUpgrade
+// reports hashes made with them so that they can be rehashed. Setting a function
+// again moves it to the front rather than trying it twice. The HMAC is base64 encoded
+// before hashing, so that with bcrypt, which takes at most 72 bytes, the digest must
+// be at most 54 bytes: SHA-512 requires argon2id, see SetArgon2Params.
+func (p *Passworder) SetPepperHash(fn func() hash.Hash) {
+	if fn == nil {
+		fn = sha256.New
+	}
+	hashes := []func() hash.Hash{fn}
+	for _, h := range p.verifyPepperHashes() {
+		if !samePepperHash(h, fn) {
+			hashes = append(hashes, h)
+		}
+	}
+	p.pepperHashes = hashes
+}
+
+// samePepperHash reports whether a and b are the same hash function, which func
+// values cannot be compared for, by their block size and digest of no input.
+func samePepperHash(a, b func() hash.Hash) bool {
+	ha, hb := a(), b()
+	return ha.BlockSize() == hb.BlockSize() && bytes.Equal(ha.Sum(nil), hb.Sum(nil))
+}
+
+// verifyPepperHashes returns the pepper hash functions accepted for verification.
+func (p *Passworder) verifyPepperHashes() []func() hash.Hash {
+	if len(p.pepperHashes) == 0 {
+		return []func() hash.Hash{sha256.New}
+	}
+	return p.pepperHashes
+}
+
+func (p *Passworder) pepperHash() func() hash.Hash {
+	if len(p.pepperHashes) == 0 {
+		return sha256.New
+	}
+	return p.pepperHashes[0]
+}
+
+// applyPepper returns the password keyed by pepper with HMAC-SHA256, nil pepper
+// returns the password unchanged.
+func applyPepper(pepper []byte, password string) string {
+	return applyPepperHash(sha256.New, pepper, password)
+}
+
+func applyPepperHash(h func() hash.Hash, pepper []byte, password string) string {
+	if pepper == nil {
+		return password
+	}
+	mac := hmac.New(h, pepper)
+	mac.Write([]byte(password))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (p *Passworder) pepper(password string) string {
+	if len(p.peppers) == 0 {
+		return password
+	}
+	return applyPepperHash(p.pepperHash(), p.peppers[0], password)
+}
+
+// HashPassword returns the bcrypt hash, or the argon2id hash if SetArgon2Params is set,
+// of the normalized password with the current pepper.
+func (p *Passworder) HashPassword(password string) (string, error) {
+	hashed, err := p.HashPasswordBytes(password)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// HashPasswordBytes is like HashPassword, but returns the hash in the configured
+// algorithm as bytes, e.g. for storing in a BYTEA column.
+func (p *Passworder) HashPasswordBytes(password string) ([]byte, error) {
+	if err := p.policy.Validate(password); err != nil {
+		return nil, err
+	}
+	return p.hash(p.normalize(password))
+}
+
+// hash hashes the normalized password.
+func (p *Passworder) hash(password string) ([]byte, error) {
+	password = p.pepper(p.prefixPepper + password)
+	if p.argon2 != nil {
+		hash, err := HashArgon2(password, *p.argon2)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(hash), nil
+	}
+	cost, err := p.hashCost()
+	if err != nil {
+		return nil, err
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), cost)
+}
+
+// ChangePassword returns the hash of newPassword, as HashPassword, to replace the hash
+// of id, e.g. after a password reset, and clears id's failed attempts so that a locked
+// out account which has recovered can log in with it right away, see SetResetOnChange.
+func (p *Passworder) ChangePassword(id any, newPassword string) (string, error) {
+	hash, err := p.HashPassword(newPassword)
+	if err != nil {
+		return "", err
+	}
+	if !p.keepOnChange {
+		p.Reset(id)
+	}
+	return hash, nil
+}
+
+// SetResetOnChange sets whether ChangePassword clears the failed attempts of the id,
+// which it does by default. Disable it to keep a lockout until it expires regardless
+// of password changes.
+func (p *Passworder) SetResetOnChange(reset bool) { p.keepOnChange = !reset }
+
+// verify compares hash with password keyed by each pepper and pepper hash function
+// in turn, rehash reports whether it matched with other than the current ones.
+// A prehashed password is compared as is.
+func (p *Passworder) verify(hash, password string, prehashed bool) (rehash bool, err error) {
+	if err := p.checkCost(hash); err != nil {
+		return false, err
+	}
+	if prehashed {
+		return false, p.checkAndCompareHash(hash, password)
+	}
+	password = p.prefixPepper + password
+	if len(p.peppers) == 0 {
+		return false, p.checkAndCompareHash(hash, password)
+	}
+	for i, pepper := range p.peppers {
+		for j, h := range p.verifyPepperHashes() {
+			if j > 0 && pepper == nil {
+				break
+			}
+			if err = p.checkAndCompareHash(hash, applyPepperHash(h, pepper, password)); err != bcrypt.ErrMismatchedHashAndPassword {
+				return err == nil && (i > 0 || j > 0), err
+			}
+		}
+	}
+	return
+}
+
+func (p *Passworder) checkAndCompareHash(hash, password string) error {
+	hash, err := p.acceptPrefix(hash)
+	if err != nil {
+		return err
+	}
+	// The prefix is normalized first, so that every accepted bcrypt prefix is checked.
+	if p.rejectLong && len(password) > 72 {
+		if alg, err := Algorithm(hash); err == nil && alg == "bcrypt" {
+			return ErrPasswordTooLong
+		}
+	}
+	return p.withTimeout(func() error { return compareHash(hash, password) })
+}
+
+// CompareAndUpgrade is like CompareHashAndPassword, rehash reports whether the hash
+// was made with a previous pepper and should be replaced by HashPassword(password).
+func (p *Passworder) CompareAndUpgrade(id any, hash, password string) (rehash bool, err error) {
+	res, err := p.compare(id, hash, password, compareOptions{hash: true})
+	return res.rehash, err
+}
+
+// CompareHashAndPasswordPrehashed is like CompareHashAndPassword, but password is
+// already keyed by the pepper outside of the package, e.g. by an HSM computing the
+// same HMAC-SHA256 as HashPassword (base64 raw standard encoded), so no pepper is applied.
+func (p *Passworder) CompareHashAndPasswordPrehashed(id any, hash, password string) error {
+	_, err := p.compare(id, hash, password, compareOptions{hash: true, prehashed: true})
+	return err
+}
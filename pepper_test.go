@@ -0,0 +1,208 @@
+package password
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPepper(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	plain, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetPepper([]byte("pepper1"))
+	if err := p.CompareHashAndPassword("", plain, "password"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	p.Reset("")
+	hash1, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rehash, err := p.CompareAndUpgrade("", hash1, "password"); err != nil {
+		t.Fatal(err)
+	} else if rehash {
+		t.Error("expected no rehash; got rehash")
+	}
+
+	p.RotatePepper([]byte("pepper2"))
+	if rehash, err := p.CompareAndUpgrade("", hash1, "password"); err != nil {
+		t.Fatal(err)
+	} else if !rehash {
+		t.Error("expected rehash; got not")
+	}
+	if _, err := p.CompareAndUpgrade("", plain, "password"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if _, err := p.CompareAndUpgrade("", hash1, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if v, _ := p.cache.Get(""); v.Count != 2 {
+		t.Errorf("expected 2; got %d", v.Count)
+	}
+	hash2, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rehash, err := p.CompareAndUpgrade("", hash2, "password"); err != nil {
+		t.Fatal(err)
+	} else if rehash {
+		t.Error("expected no rehash; got rehash")
+	}
+}
+
+func TestRotatePepperFromNone(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	plain, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RotatePepper([]byte("pepper"))
+	if rehash, err := p.CompareAndUpgrade("", plain, "password"); err != nil {
+		t.Fatal(err)
+	} else if !rehash {
+		t.Error("expected rehash; got not")
+	}
+}
+
+func TestPrehashed(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetPepper([]byte("pepper"))
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPasswordPrehashed("", hash, applyPepper([]byte("pepper"), "password")); err != nil {
+		t.Error(err)
+	}
+	if err := p.CompareHashAndPasswordPrehashed("", hash, "password"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+}
+
+func TestSetPeppers(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	var hashes []string
+	for _, pepper := range [][]byte{[]byte("pepper3"), []byte("pepper2"), nil} {
+		p.SetPepper(pepper)
+		hash, err := p.HashPassword("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	p.SetPeppers([][]byte{[]byte("pepper3"), []byte("pepper2"), nil})
+	for i, hash := range hashes {
+		if rehash, err := p.CompareAndUpgrade("", hash, "password"); err != nil {
+			t.Fatal(err)
+		} else if rehash != (i > 0) {
+			t.Errorf("#%d: expected rehash %v; got %v", i, i > 0, rehash)
+		}
+	}
+	p.SetPeppers([][]byte{[]byte("pepper3")})
+	if _, err := p.CompareAndUpgrade("", hashes[1], "password"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+}
+
+func TestSetPepperHash(t *testing.T) {
+	// RFC 4231 test case 2.
+	for _, tc := range []struct {
+		h        func() hash.Hash
+		expected string
+	}{
+		{sha256.New, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{sha512.New, "164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea2505549758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737"},
+	} {
+		b, _ := hex.DecodeString(tc.expected)
+		if s := applyPepperHash(tc.h, []byte("Jefe"), "what do ya want for nothing?"); s != base64.RawStdEncoding.EncodeToString(b) {
+			t.Errorf("expected %x; got %s", b, s)
+		}
+	}
+
+	p := New(24*time.Hour, 5, nil)
+	p.SetArgon2Params(&Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+	p.SetPepper([]byte("pepper"))
+	old, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetPepperHash(sha512.New)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rehash, err := p.CompareAndUpgrade("", hash, "password"); err != nil || rehash {
+		t.Errorf("expected current hash; got %v, %v", rehash, err)
+	}
+	if rehash, err := p.CompareAndUpgrade("", old, "password"); err != nil || !rehash {
+		t.Errorf("expected rehash of SHA-256 peppered hash; got %v, %v", rehash, err)
+	}
+	p.SetPepperHash(sha512.New)
+	if n := len(p.pepperHashes); n != 2 {
+		t.Errorf("expected 2 pepper hashes after setting one again; got %d", n)
+	}
+	p.SetPepperHash(sha512.New384)
+	for _, h := range []string{old, hash} {
+		if rehash, err := p.CompareAndUpgrade("", h, "password"); err != nil || !rehash {
+			t.Errorf("expected rehash of previous pepper hashes; got %v, %v", rehash, err)
+		}
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	p := New(24*time.Hour, 2, nil)
+	for range 2 {
+		p.Compare("id", "password", "wrongpassword")
+	}
+	hash, err := p.ChangePassword("id", "newpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPassword("id", hash, "newpassword"); err != nil {
+		t.Errorf("expected lockout cleared; got %v", err)
+	}
+
+	p.SetResetOnChange(false)
+	for range 2 {
+		p.Compare("id", "password", "wrongpassword")
+	}
+	if hash, err = p.ChangePassword("id", "newpassword"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPassword("id", hash, "newpassword"); !errors.Is(err, ErrMaxPasswordAttempts) {
+		t.Errorf("expected lockout kept; got %v", err)
+	}
+}
+
+func TestPrefixPepper(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("static-pepper"+"password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(24*time.Hour, 5, nil)
+	p.SetPrefixPepper("static-pepper")
+	if err := p.CompareHashAndPassword("id", string(legacy), "password"); err != nil {
+		t.Error(err)
+	}
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("static-pepperpassword")); err != nil {
+		t.Errorf("expected prefix applied when hashing; got %v", err)
+	}
+	p.SetPrefixPepper("")
+	if err := p.CompareHashAndPassword("id", string(legacy), "password"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+package password
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword is returned when a password is weaker than the policy requires.
+var ErrWeakPassword = errors.New("password is too weak")
+
+// ErrCommonPassword is returned when a password is in the policy's denylist.
+var ErrCommonPassword = errors.New("password is too common")
+
+// Policy violations, Validate joins those of a password with errors.Join.
+var (
+	ErrTooShort = errors.New("password is too short")
+	ErrNoUpper  = errors.New("password has no upper case letter")
+	ErrNoLower  = errors.New("password has no lower case letter")
+	ErrNoDigit  = errors.New("password has no digit")
+	ErrNoSymbol = errors.New("password has no symbol")
+)
+
+// Policy is the requirements a new password must satisfy.
+type Policy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+	// RequireUpper, RequireLower, RequireDigit and RequireSymbol require at least
+	// one character of the class.
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// MinScore is the minimum strength score from 0 to 4, see EstimateStrength.
+	MinScore int
+	// Rules are custom rules the password must also satisfy, the errors they return
+	// are violations like the built-in ones.
+	Rules []PolicyFunc
+	// Severity overrides the severity of violations, keyed by their error such as
+	// ErrNoSymbol. Violations not in it are rejected.
+	Severity map[error]Severity
+
+	denylist map[string]struct{}
+	combined []Policy
+}
+
+// PolicyFunc is a custom policy rule, it returns the violation of password or nil.
+type PolicyFunc func(password string) error
+
+// CombinePolicies returns a policy which requires a password to satisfy every one
+// of policies, such as a company-wide policy and a team-specific one. Its Validate
+// joins the violations of all of them. The Severity of each policy applies to its
+// own violations, and a violation rejected by any policy is rejected.
+func CombinePolicies(policies ...Policy) Policy {
+	return Policy{combined: append([]Policy(nil), policies...)}
+}
+
+// Severity is how a policy treats a violation.
+type Severity int
+
+const (
+	// SeverityReject rejects the password.
+	SeverityReject Severity = iota
+	// SeverityWarn accepts the password but reports the violation, e.g. during a
+	// grace period before a stricter policy is enforced.
+	SeverityWarn
+)
+
+// LoadDenylist adds the newline-separated passwords read from r, such as a
+// top 10k common passwords list, to the passwords the policy rejects regardless
+// of case with ErrCommonPassword.
+func (p *Policy) LoadDenylist(r io.Reader) error {
+	if p.denylist == nil {
+		p.denylist = make(map[string]struct{})
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			p.denylist[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return scanner.Err()
+}
+
+// Validate checks password against the policy. It returns nil or the errors.Join
+// of every rejected violation, so that each can be tested with errors.Is, e.g.
+// ErrTooShort. Violations of SeverityWarn are ignored, see Check.
+func (p Policy) Validate(password string) error {
+	_, err := p.Check(password)
+	return err
+}
+
+// Check is like Validate, but also returns the violations of SeverityWarn.
+func (p Policy) Check(password string) (warnings []error, err error) {
+	var errs []error
+	p.check(password, &warnings, &errs)
+	var n int
+	for _, w := range warnings {
+		if !containsError(errs, w) {
+			warnings[n] = w
+			n++
+		}
+	}
+	return warnings[:n], errors.Join(errs...)
+}
+
+func (p Policy) check(password string, warnings, errs *[]error) {
+	for _, v := range p.violations(password) {
+		if p.severity(v) == SeverityWarn {
+			if !containsError(*warnings, v) {
+				*warnings = append(*warnings, v)
+			}
+		} else if !containsError(*errs, v) {
+			*errs = append(*errs, v)
+		}
+	}
+	for _, i := range p.combined {
+		i.check(password, warnings, errs)
+	}
+}
+
+func (p Policy) severity(v error) Severity {
+	// Errors returned by custom rules may not be comparable, and so not map keys.
+	if !reflect.TypeOf(v).Comparable() {
+		return SeverityReject
+	}
+	return p.Severity[v]
+}
+
+func containsError(errs []error, err error) bool {
+	for _, i := range errs {
+		if errors.Is(i, err) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Policy) violations(password string) (errs []error) {
+	if _, ok := p.denylist[strings.ToLower(password)]; ok {
+		errs = append(errs, ErrCommonPassword)
+	}
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		errs = append(errs, ErrTooShort)
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	for _, i := range []struct {
+		require, ok bool
+		err         error
+	}{
+		{p.RequireUpper, upper, ErrNoUpper},
+		{p.RequireLower, lower, ErrNoLower},
+		{p.RequireDigit, digit, ErrNoDigit},
+		{p.RequireSymbol, symbol, ErrNoSymbol},
+	} {
+		if i.require && !i.ok {
+			errs = append(errs, i.err)
+		}
+	}
+	if p.MinScore > 0 && EstimateStrength(password) < p.MinScore {
+		errs = append(errs, ErrWeakPassword)
+	}
+	for _, rule := range p.Rules {
+		if err := rule(password); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// SetPolicy makes HashPassword reject passwords which do not satisfy policy.
+func (p *Passworder) SetPolicy(policy Policy) { p.policy = policy }
+
+// ValidateAndHash is like HashPassword, but also returns the messages of the policy
+// violations of SeverityWarn, so that the user can be asked to choose a stronger
+// password while the weak one is still accepted.
+func (p *Passworder) ValidateAndHash(password string) (hash string, warnings []string, err error) {
+	violations, err := p.policy.Check(password)
+	if err != nil {
+		return "", nil, err
+	}
+	hashed, err := p.hash(p.normalize(password))
+	if err != nil {
+		return "", nil, err
+	}
+	for _, v := range violations {
+		warnings = append(warnings, v.Error())
+	}
+	return string(hashed), warnings, nil
+}
+
+var strengthFunc = estimateStrength
+
+// SetStrengthFunc replaces the strength estimator used by EstimateStrength and
+// Policy.Validate, such as a zxcvbn implementation. nil restores the built-in one.
+func SetStrengthFunc(fn func(password string) int) {
+	if fn == nil {
+		fn = estimateStrength
+	}
+	strengthFunc = fn
+}
+
+// EstimateStrength returns a strength score of password from 0 (too guessable)
+// to 4 (very unguessable), in the manner of zxcvbn.
+//
+// The built-in estimator is deliberately lightweight: common passwords score 0,
+// otherwise the score is derived from the entropy of the character classes used,
+// discounting repeated characters and sequences such as "aaa" or "1234".
+func EstimateStrength(password string) int {
+	return min(max(strengthFunc(password), 0), 4)
+}
+
+var commonPasswords = map[string]struct{}{}
+
+func init() {
+	for _, i := range strings.Fields(`
+123456 123456789 12345678 12345 1234567 1234567890 111111 000000 123123 654321
+password password1 password123 passw0rd qwerty qwerty123 qwertyuiop 1q2w3e4r
+abc123 admin letmein welcome iloveyou monkey dragon football baseball sunshine
+princess master shadow superman trustno1 login starwars whatever hello freedom`) {
+		commonPasswords[i] = struct{}{}
+	}
+}
+
+func estimateStrength(password string) int {
+	if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+		return 0
+	}
+	var lower, upper, digit, other bool
+	var length int
+	var prev rune = -1
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+		// Repeated characters and ascending or descending sequences add little.
+		if d := r - prev; d < -1 || d > 1 {
+			length++
+		}
+		prev = r
+	}
+	var pool int
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if other {
+		pool += 33
+	}
+	if pool == 0 {
+		return 0
+	}
+	switch bits := float64(length) * math.Log2(float64(pool)); {
+	case bits < 25:
+		return 0
+	case bits < 35:
+		return 1
+	case bits < 50:
+		return 2
+	case bits < 65:
+		return 3
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,143 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateStrength(t *testing.T) {
+	for password, expected := range map[string]int{
+		"":                             0,
+		"password":                     0,
+		"Password1":                    0,
+		"aaaaaaaaaaaa":                 0,
+		"abcdefghijkl":                 0,
+		"kitten":                       0,
+		"kittens":                      1,
+		"kitten42":                     2,
+		"Kitten42!x":                   3,
+		"correct horse battery staple": 4,
+	} {
+		if score := EstimateStrength(password); score != expected {
+			t.Errorf("%q: expected %d; got %d", password, expected, score)
+		}
+	}
+}
+
+func TestPolicyMinScore(t *testing.T) {
+	p := Policy{MinScore: 3}
+	if err := p.Validate("kitten42"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("expected ErrWeakPassword; got %v", err)
+	}
+	if err := p.Validate("Kitten42!x"); err != nil {
+		t.Error(err)
+	}
+	if err := (Policy{}).Validate("password"); err != nil {
+		t.Error(err)
+	}
+
+	SetStrengthFunc(func(string) int { return 10 })
+	defer SetStrengthFunc(nil)
+	if score := EstimateStrength("password"); score != 4 {
+		t.Errorf("expected 4; got %d", score)
+	}
+	if err := p.Validate("kitten42"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPolicyDenylist(t *testing.T) {
+	var p Policy
+	if err := p.LoadDenylist(strings.NewReader("Summer2024!\r\n\ncorrect horse battery staple\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range []string{"summer2024!", "SUMMER2024!", "correct horse battery staple"} {
+		if err := p.Validate(password); !errors.Is(err, ErrCommonPassword) {
+			t.Errorf("%q: expected ErrCommonPassword; got %v", password, err)
+		}
+	}
+	if err := p.Validate("Kitten42!x"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPolicyViolations(t *testing.T) {
+	p := Policy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	err := p.Validate("kitten")
+	for _, e := range []error{ErrTooShort, ErrNoUpper, ErrNoDigit, ErrNoSymbol} {
+		if !errors.Is(err, e) {
+			t.Errorf("expected %v in %v", e, err)
+		}
+	}
+	if errors.Is(err, ErrNoLower) {
+		t.Errorf("expected no ErrNoLower in %v", err)
+	}
+	if err := p.Validate("Kitten42!xyz"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCombinePolicies(t *testing.T) {
+	errCompanyName := errors.New("password contains the company name")
+	company := Policy{MinLength: 8, RequireDigit: true, Rules: []PolicyFunc{
+		func(password string) error {
+			if strings.Contains(strings.ToLower(password), "acme") {
+				return errCompanyName
+			}
+			return nil
+		},
+	}}
+	team := Policy{MinLength: 12, RequireDigit: true, RequireSymbol: true, Severity: map[error]Severity{ErrNoSymbol: SeverityWarn}}
+	p := CombinePolicies(company, team)
+	err := p.Validate("acme")
+	for _, e := range []error{ErrTooShort, ErrNoDigit, errCompanyName} {
+		if !errors.Is(err, e) {
+			t.Errorf("expected %v in %v", e, err)
+		}
+	}
+	if errs := err.(interface{ Unwrap() []error }).Unwrap(); len(errs) != 3 {
+		t.Errorf("expected 3 distinct violations; got %v", errs)
+	}
+	if err := p.Validate("kitten42"); !errors.Is(err, ErrTooShort) {
+		t.Errorf("expected ErrTooShort of the team policy; got %v", err)
+	}
+	warnings, err := p.Check("kittens42xyz")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(warnings) != 1 || warnings[0] != ErrNoSymbol {
+		t.Errorf("expected symbol warning; got %v", warnings)
+	}
+	company.RequireSymbol = true
+	if _, err := CombinePolicies(company, team).Check("kittens42xyz"); !errors.Is(err, ErrNoSymbol) {
+		t.Errorf("expected ErrNoSymbol rejected by the company policy; got %v", err)
+	}
+}
+
+func TestValidateAndHash(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetPolicy(Policy{
+		MinLength:     8,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		Severity:      map[error]Severity{ErrNoSymbol: SeverityWarn},
+	})
+	hash, warnings, err := p.ValidateAndHash("kitten42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0] != ErrNoSymbol.Error() {
+		t.Errorf("expected symbol warning; got %q", warnings)
+	}
+	if err := p.CompareHashAndPassword("id", hash, "kitten42"); err != nil {
+		t.Error(err)
+	}
+	if _, err := p.HashPassword("kitten42"); err != nil {
+		t.Errorf("expected warned violation accepted; got %v", err)
+	}
+	if _, _, err := p.ValidateAndHash("kitten"); !errors.Is(err, ErrTooShort) || !errors.Is(err, ErrNoDigit) || errors.Is(err, ErrNoSymbol) {
+		t.Errorf("expected only rejected violations; got %v", err)
+	}
+}
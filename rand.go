@@ -0,0 +1,22 @@
+package password
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+var random io.Reader = rand.Reader
+
+// SetTestRand replaces the source of randomness used across the package, such as
+// lockout jitter and argon2 salts, with r so that tests are reproducible. nil
+// restores crypto/rand.Reader. bcrypt salts are drawn by the bcrypt package itself
+// and are not affected.
+//
+// It must never be used in production: a predictable source makes salts and
+// jitter predictable.
+func SetTestRand(r io.Reader) {
+	if r == nil {
+		r = rand.Reader
+	}
+	random = r
+}
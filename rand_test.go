@@ -0,0 +1,30 @@
+package password
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSetTestRand(t *testing.T) {
+	defer SetTestRand(nil)
+	params := Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+	var hashes [2]string
+	for i := range hashes {
+		SetTestRand(bytes.NewReader(make([]byte, 16)))
+		hash, err := HashArgon2("password", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[i] = hash
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("expected identical hashes; got %s and %s", hashes[0], hashes[1])
+	}
+	SetTestRand(bytes.NewReader(make([]byte, 64)))
+	p := New(time.Minute, 5, nil)
+	p.SetLockoutJitter(time.Second)
+	if d := p.ttl(); d != time.Minute {
+		t.Errorf("expected %s; got %s", time.Minute, d)
+	}
+}
@@ -0,0 +1,32 @@
+package password
+
+import "time"
+
+// SetRateLimit switches the lockout from a fixed window counter to a token bucket
+// of capacity tokens: each incorrect password consumes a token, one token is
+// refilled every refill, and ErrMaxPasswordAttempts is returned while the bucket
+// is empty. This throttles guessing smoothly instead of unlocking all at once when
+// the window expires. capacity replaces the maximum password attempts.
+// Zero refill switches back to the fixed window counter.
+func (l *AttemptLimiter) SetRateLimit(capacity int, refill time.Duration) {
+	l.max = capacity
+	l.refill = refill
+}
+
+// refillRecord gives back the tokens refilled since the last refill.
+func (l *AttemptLimiter) refillRecord(v *Record, now time.Time) {
+	if l.refill <= 0 || v.Count == 0 {
+		return
+	}
+	n := int(now.Sub(v.Refilled) / l.refill)
+	if n <= 0 {
+		return
+	}
+	if n >= v.Count {
+		v.Count = 0
+		v.Refilled = now
+		return
+	}
+	v.Count -= n
+	v.Refilled = v.Refilled.Add(time.Duration(n) * l.refill)
+}
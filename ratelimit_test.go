@@ -0,0 +1,156 @@
+package password
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	p := New(24*time.Hour, 5, nil)
+	p.now = func() time.Time { return now }
+	p.SetRateLimit(3, time.Minute)
+	for range 3 {
+		if err := p.Compare("id", "password", "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("expected ErrIncorrectPassword; got %v", err)
+		}
+	}
+	if err := p.Compare("id", "password", "password"); !errors.Is(err, ErrMaxPasswordAttempts) {
+		t.Fatalf("expected ErrMaxPasswordAttempts; got %v", err)
+	}
+	now = now.Add(90 * time.Second)
+	if v, _ := p.Attempts("id"); v.Count != 2 {
+		t.Errorf("expected 2; got %d", v.Count)
+	}
+	if err := p.Compare("id", "password", "wrongpassword"); err != (incorrectPasswordError{3, nil}) {
+		t.Fatalf("expected incorrect password 3; got %v", err)
+	}
+	if !p.IsMaxAttempts("id") {
+		t.Error("expected max attempts; got not")
+	}
+	// The half minute left over is kept towards the next token.
+	now = now.Add(30 * time.Second)
+	if v, _ := p.Attempts("id"); v.Count != 2 {
+		t.Errorf("expected 2; got %d", v.Count)
+	}
+	now = now.Add(time.Hour)
+	if v, _ := p.Attempts("id"); v.Count != 0 {
+		t.Errorf("expected 0; got %d", v.Count)
+	}
+	if d := p.ttl(); d != 3*time.Minute {
+		t.Errorf("expected 3m; got %s", d)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewAttemptLimiter(24*time.Hour, 4)
+	l.now = func() time.Time { return now }
+	l.SetSlidingWindow(4, 4*time.Minute)
+	l.Fail("id")
+	l.Fail("id")
+	now = now.Add(2 * time.Minute)
+	l.Fail("id")
+	l.Fail("id")
+	if !l.IsMaxAttempts("id") {
+		t.Fatal("expected max attempts; got not")
+	}
+	if d := l.LockoutRemaining("id"); d != 2*time.Minute {
+		t.Errorf("expected 2m; got %s", d)
+	}
+	// The first bucket leaves the window, the second one is still counted,
+	// so the id does not get a fresh maximum of attempts at once.
+	now = now.Add(2 * time.Minute)
+	if v, _ := l.Attempts("id"); v.Count != 2 {
+		t.Errorf("expected 2; got %d", v.Count)
+	}
+	l.Fail("id")
+	l.Fail("id")
+	if !l.IsMaxAttempts("id") {
+		t.Error("expected max attempts; got not")
+	}
+	l.Decrement("id", 3)
+	if v, _ := l.Attempts("id"); v.Count != 1 {
+		t.Errorf("expected 1; got %d", v.Count)
+	}
+	now = now.Add(4 * time.Minute)
+	if _, ok := l.Attempts("id"); ok {
+		t.Error("expected record expired")
+	}
+}
+
+func TestMaxAttemptsHashedPlain(t *testing.T) {
+	p := New(24*time.Hour, 3, nil)
+	p.SetMaxAttemptsHashed(2)
+	p.SetMaxAttemptsPlain(5)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		p.CompareHashAndPassword("user", hash, "wrongpassword")
+	}
+	var e MaxAttemptsError
+	if err := p.CompareHashAndPassword("user", hash, "password"); !errors.As(err, &e) || e.Max != 2 {
+		t.Errorf("expected MaxAttemptsError with max 2; got %v", err)
+	}
+	if s := p.StatusHashed("user"); !s.Locked || s.Max != 2 || s.RetryAfter <= 0 {
+		t.Errorf("expected hashed status to agree with the comparison; got %+v", s)
+	}
+	if s := p.StatusPlain("user"); s.Locked || s.Max != 5 || s.Attempts != 2 {
+		t.Errorf("expected plaintext status unlocked; got %+v", s)
+	}
+	if s := p.Status("user"); s.Locked != p.IsMaxAttempts("user") || s.Max != 3 {
+		t.Errorf("expected Status to report SetMaxAttempts like IsMaxAttempts; got %+v", s)
+	}
+	for range 4 {
+		if err := p.Compare("key", "secret", "wrongsecret"); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("expected ErrIncorrectPassword; got %v", err)
+		}
+	}
+	if err := p.Compare("key", "secret", "secret"); err != nil {
+		t.Errorf("expected plaintext comparison allowed below 5 attempts; got %v", err)
+	}
+	p.SetMaxAttemptsPlain(0)
+	for range 3 {
+		p.Compare("key", "secret", "wrongsecret")
+	}
+	if err := p.Compare("key", "secret", "secret"); !errors.Is(err, ErrMaxPasswordAttempts) {
+		t.Errorf("expected default maximum; got %v", err)
+	}
+}
+
+func TestCaptchaThreshold(t *testing.T) {
+	p := New(24*time.Hour, 4, nil)
+	p.SetCaptchaThreshold(2)
+	for range 2 {
+		if err := p.Compare("id", "password", "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("expected ErrIncorrectPassword; got %v", err)
+		}
+	}
+	if err := p.Compare("id", "password", "password"); err != ErrCaptchaRequired {
+		t.Errorf("expected ErrCaptchaRequired; got %v", err)
+	}
+	if v, _ := p.Attempts("id"); v.Count != 2 {
+		t.Errorf("expected captcha gate not counted; got %d", v.Count)
+	}
+	if err := p.CompareWithCaptchaSolved("id", "password", "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if err := p.CompareWithCaptchaSolved("id", "password", "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if err := p.CompareWithCaptchaSolved("id", "password", "password"); !errors.Is(err, ErrMaxPasswordAttempts) {
+		t.Errorf("expected ErrMaxPasswordAttempts; got %v", err)
+	}
+	p.Reset("id")
+	p.Compare("id", "password", "wrongpassword")
+	p.Compare("id", "password", "wrongpassword")
+	if err := p.CompareWithCaptchaSolved("id", "password", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Compare("id", "password", "password"); err != nil {
+		t.Errorf("expected captcha gate cleared on success; got %v", err)
+	}
+}
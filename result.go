@@ -0,0 +1,97 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"io"
+	"sync"
+	"time"
+)
+
+// resultCache remembers the results of recent hash comparisons, so that identical
+// retries of a request within ttl do not repeat the key derivation.
+type resultCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	secret []byte
+	m      map[resultKey]cachedResult
+	now    func() time.Time
+	sweep  time.Time
+}
+
+// resultKey identifies a comparison, the password is kept only as a keyed digest.
+// It includes how the password is decrypted and verified, so that a result is never
+// served to another comparison variant, e.g. a prehashed one to a peppered one.
+type resultKey struct {
+	id       any
+	hash     string
+	password [sha256.Size]byte
+
+	priv         *rsa.PrivateKey // nil if not decrypted
+	prehashed    bool
+	clientHashed bool
+	expires      time.Time // expiry the password is bound to
+}
+
+type cachedResult struct {
+	res     compareResult
+	err     error
+	expires time.Time
+}
+
+// SetResultCache makes hash comparisons remember their result for ttl, so that an
+// identical retry of id, hash and password, e.g. by a client on a flaky network,
+// returns it without hashing again. A failure returned from the cache is not counted
+// again, a different password is never served from the cache, and ids are still
+// checked for lockout first. Keep ttl short, a few hundred milliseconds. Zero ttl
+// disables it, as does a failure to draw its secret from the random source.
+func (p *Passworder) SetResultCache(ttl time.Duration) {
+	p.results = nil
+	if ttl <= 0 {
+		return
+	}
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(random, secret); err != nil {
+		return
+	}
+	p.results = &resultCache{
+		ttl:    ttl,
+		secret: secret,
+		m:      make(map[resultKey]cachedResult),
+		now:    func() time.Time { return p.now() },
+	}
+}
+
+func (c *resultCache) key(id any, hash, password string) resultKey {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(password))
+	k := resultKey{id: id, hash: hash}
+	mac.Sum(k.password[:0])
+	return k
+}
+
+func (c *resultCache) get(k resultKey) (cachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.m[k]
+	if !ok || !c.now().Before(v.expires) {
+		return cachedResult{}, false
+	}
+	return v, true
+}
+
+func (c *resultCache) set(k resultKey, res compareResult, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.After(c.sweep) {
+		for k, v := range c.m {
+			if !now.Before(v.expires) {
+				delete(c.m, k)
+			}
+		}
+		c.sweep = now.Add(c.ttl)
+	}
+	c.m[k] = cachedResult{res: res, err: err, expires: now.Add(c.ttl)}
+}
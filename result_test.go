@@ -0,0 +1,89 @@
+package password
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	p.SetResultCache(500 * time.Millisecond)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := p.CompareHashAndPassword("id", hash, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+			t.Errorf("expected ErrIncorrectPassword; got %v", err)
+		}
+	}
+	if v, _ := p.Attempts("id"); v.Count != 1 {
+		t.Errorf("expected 1 attempt for identical retries; got %d", v.Count)
+	}
+	if err := p.CompareHashAndPassword("id", hash, "wrongpassword2"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	if v, _ := p.Attempts("id"); v.Count != 2 {
+		t.Errorf("expected 2; got %d", v.Count)
+	}
+	now = now.Add(time.Second)
+	p.CompareHashAndPassword("id", hash, "wrongpassword")
+	if v, _ := p.Attempts("id"); v.Count != 3 {
+		t.Errorf("expected 3 after cache expiry; got %d", v.Count)
+	}
+	if err := p.CompareHashAndPassword("id", hash, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.results.get(p.results.key("id", hash, "password")); !ok {
+		t.Error("expected success to be cached")
+	}
+	if err := p.CompareHashAndPassword("id", hash, "password"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResultCacheVariants(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetPepper([]byte("pepper"))
+	p.SetResultCache(time.Minute)
+	hash, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prehashed := applyPepper([]byte("pepper"), "password")
+	if err := p.CompareHashAndPasswordPrehashed("id", hash, prehashed); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CompareHashAndPassword("id", hash, prehashed); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected prehashed success not served to a peppered comparison; got %v", err)
+	}
+
+	for range 3 {
+		p.CompareHashAndPasswordWithDisplay("id", "device", hash, "wrongpassword")
+	}
+	if n := p.DisplayAttempts("device"); n != 1 {
+		t.Errorf("expected 1 display attempt for identical retries; got %d", n)
+	}
+}
+
+func TestResultCacheRand(t *testing.T) {
+	defer SetTestRand(nil)
+	p := New(24*time.Hour, 5, nil)
+	var secrets [2][]byte
+	for i := range secrets {
+		SetTestRand(bytes.NewReader(make([]byte, 32)))
+		p.SetResultCache(time.Second)
+		secrets[i] = p.results.secret
+	}
+	if !bytes.Equal(secrets[0], secrets[1]) {
+		t.Errorf("expected secrets from the test source; got %x and %x", secrets[0], secrets[1])
+	}
+	SetTestRand(bytes.NewReader(nil))
+	if p.SetResultCache(time.Second); p.results != nil {
+		t.Error("expected cache disabled without randomness")
+	}
+}
@@ -0,0 +1,37 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// SetClientHMACKey requires encrypted passwords to be signed by clients sharing key:
+// the password is sent as ciphertext + "." + HMAC-SHA256 of the ciphertext bytes
+// under key, both encoded with the configured encoding. Unsigned payloads and
+// signature mismatches fail with ErrTamperedPayload before decryption, and are
+// counted like decryption failures. nil key disables it.
+func (p *Passworder) SetClientHMACKey(key []byte) { p.clientMAC = key }
+
+// verifyPayload checks the signature of payload and returns its ciphertext.
+func (p *Passworder) verifyPayload(payload string) (string, error) {
+	ciphertext, sig, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", ErrTamperedPayload
+	}
+	b, err := p.enc.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w: %w", ErrTamperedPayload, ErrInvalidCiphertextEncoding, err)
+	}
+	mac, err := p.enc.DecodeString(sig)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w: %w", ErrTamperedPayload, ErrInvalidCiphertextEncoding, err)
+	}
+	h := hmac.New(sha256.New, p.clientMAC)
+	h.Write(b)
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return "", ErrTamperedPayload
+	}
+	return ciphertext, nil
+}
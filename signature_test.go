@@ -0,0 +1,56 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClientHMACKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("shared secret")
+	mac := hmac.New(sha256.New, key)
+	mac.Write(ciphertext)
+	encrypted := base64.StdEncoding.EncodeToString(ciphertext)
+	signed := encrypted + "." + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	p := New(24*time.Hour, 5, priv)
+	p.SetClientHMACKey(key)
+	if err := p.Compare("id", "password", signed); err != nil {
+		t.Error(err)
+	}
+	for i, payload := range []string{
+		encrypted,
+		encrypted + "." + base64.StdEncoding.EncodeToString([]byte("forged")),
+		"x" + signed[1:],
+	} {
+		if err := p.Compare("id", "password", payload); err != ErrTamperedPayload {
+			t.Errorf("#%d: expected ErrTamperedPayload; got %v", i, err)
+		}
+		p.Reset("id")
+	}
+
+	p.SetLockOnDecryptError(false)
+	if err := p.Compare("id", "password", encrypted+".not base64!"); !errors.Is(err, ErrTamperedPayload) || !errors.Is(err, ErrInvalidCiphertextEncoding) {
+		t.Errorf("expected ErrTamperedPayload and ErrInvalidCiphertextEncoding; got %v", err)
+	}
+	if v, ok := p.Attempts("id"); ok && v.Count != 0 || p.IsMaxAttempts("id") {
+		t.Errorf("expected no attempt counted for a malformed payload with lock disabled; got %+v", v)
+	}
+	p.Compare("id", "password", encrypted)
+	if !p.IsMaxAttempts("id") {
+		t.Error("expected locked out by a signature mismatch with lock disabled; got not")
+	}
+}
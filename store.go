@@ -0,0 +1,195 @@
+package password
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/sunshineplan/utils/cache"
+)
+
+// Store keeps the records of an AttemptLimiter, e.g. in Redis so that lockouts are
+// shared by several instances. Get must not return a record at or past its Expires,
+// which a store with native expiry can use as the key's deadline. Records of ids
+// never seen again are left to expire. A store which cannot reach its backend decides
+// itself whether to fail open, returning no record, or closed.
+//
+// A store may also implement All() map[any]Record for Snapshot and SyncAll,
+// Purge() int for PurgeExpired, Clear() for Close and Incrementer for failures.
+type Store interface {
+	Get(id any) (Record, bool)
+	Set(id any, v Record)
+	Delete(id any)
+}
+
+// Incrementer is implemented by stores which can add to the count of a record
+// atomically, e.g. with a Redis script, so that concurrent failures of several
+// instances are not lost. Increment adds delta to id's count, capped at max if it is
+// positive, and sets its LastFail and LastSource to those of fail. If id has no record,
+// it is created with the FirstFail and Expires of fail. It returns the new record and
+// the count before, and Get must still return that record. It is not used with
+// SetRateLimit or SetSlidingWindow. On error, the record is read and set instead.
+type Incrementer interface {
+	Increment(id any, delta, max int, fail Record) (v Record, prev int, err error)
+}
+
+var _ Store = new(memoryStore)
+
+// SetStore sets the store of the records, nil restores a new in-memory store.
+// Records already in the previous store are not carried over.
+func (l *AttemptLimiter) SetStore(s Store) {
+	if s == nil {
+		m := newMemoryStore(func() time.Time { return l.now() })
+		m.max = l.maxIDs
+		s = m
+	}
+	l.cache = s
+}
+
+// SetMaxTrackedIDs bounds the in-memory store to n ids, evicting the least recently
+// used records beyond it, so that failures of many one-off ids, e.g. by a password
+// spraying attack with random usernames, cannot exhaust memory before they expire.
+// Evicting a record resets the id's failed attempts and so lifts its lockout: n should
+// be well above the number of ids legitimately failing within the duration, as a
+// flood of new ids can otherwise be used to unlock an id. Zero n means no bound,
+// the default. It has no effect on a store set by SetStore.
+func (l *AttemptLimiter) SetMaxTrackedIDs(n int) {
+	l.maxIDs = n
+	if s, ok := l.cache.(*memoryStore); ok {
+		s.setMax(n)
+	}
+}
+
+// all returns the unexpired records if the store can list them.
+func (l *AttemptLimiter) all() map[any]Record {
+	if s, ok := l.cache.(interface{ All() map[any]Record }); ok {
+		return s.All()
+	}
+	return nil
+}
+
+// sweepInterval is the minimum interval between sweeps of expired records.
+const sweepInterval = time.Minute
+
+// memoryStore is an in-memory store of records. Records are expired by the timers of
+// a cache, which Clear releases, and also dropped on access once past their Expires,
+// so that the limiter's clock is honored. Records whose timers fired are swept from
+// the index from time to time on write. With a maximum, the least recently used
+// records are evicted beyond it.
+type memoryStore struct {
+	mu    sync.Mutex
+	cache *cache.Cache[any, Record]
+	m     map[any]*list.Element // of ids, to list and evict records
+	lru   *list.List            // most recently used first
+	max   int
+	now   func() time.Time
+	sweep time.Time
+}
+
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{cache: cache.New[any, Record](true), m: make(map[any]*list.Element), lru: list.New(), now: now}
+}
+
+func (s *memoryStore) Get(id any) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.m[id]
+	if !ok {
+		return Record{}, false
+	}
+	v, ok := s.cache.Get(id)
+	if !ok || !s.now().Before(v.Expires) {
+		s.remove(e)
+		return Record{}, false
+	}
+	s.lru.MoveToFront(e)
+	return v, true
+}
+
+func (s *memoryStore) Set(id any, v Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.After(s.sweep) {
+		s.purge(now)
+		s.sweep = now.Add(sweepInterval)
+	}
+	// Deleting first stops the timer of the previous record, which would otherwise
+	// delete this one when it fires.
+	s.cache.Delete(id)
+	s.cache.Set(id, v, v.Expires.Sub(now), nil)
+	if e, ok := s.m[id]; ok {
+		s.lru.MoveToFront(e)
+		return
+	}
+	s.m[id] = s.lru.PushFront(id)
+	s.evict()
+}
+
+// setMax sets the maximum number of records, zero means no maximum.
+func (s *memoryStore) setMax(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max = n
+	s.evict()
+}
+
+func (s *memoryStore) evict() {
+	for s.max > 0 && s.lru.Len() > s.max {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *memoryStore) remove(e *list.Element) {
+	delete(s.m, e.Value)
+	s.lru.Remove(e)
+	s.cache.Delete(e.Value)
+}
+
+// All returns a copy of the unexpired records.
+func (s *memoryStore) All() map[any]Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	m := make(map[any]Record, len(s.m))
+	for id := range s.m {
+		if v, ok := s.cache.Get(id); ok && now.Before(v.Expires) {
+			m[id] = v
+		}
+	}
+	return m
+}
+
+func (s *memoryStore) Delete(id any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.m[id]; ok {
+		s.remove(e)
+	}
+}
+
+// Clear deletes all records and stops their timers.
+func (s *memoryStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.Clear()
+	clear(s.m)
+	s.lru.Init()
+}
+
+// Purge removes the expired records and returns how many were removed.
+func (s *memoryStore) Purge() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.purge(s.now())
+}
+
+func (s *memoryStore) purge(now time.Time) (n int) {
+	for _, e := range s.m {
+		if v, ok := s.cache.Get(e.Value); !ok || !now.Before(v.Expires) {
+			s.remove(e)
+			n++
+		}
+	}
+	return
+}
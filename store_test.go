@@ -0,0 +1,225 @@
+package password
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+	s := newMemoryStore(func() time.Time { return now })
+	s.Set("a", Record{Count: 1, Expires: now.Add(time.Minute)})
+	s.Set("b", Record{Count: 2, Expires: now.Add(time.Hour)})
+	if v, ok := s.Get("a"); !ok || v.Count != 1 {
+		t.Errorf("expected 1, true; got %d, %v", v.Count, ok)
+	}
+	now = now.Add(time.Minute)
+	if _, ok := s.Get("a"); ok {
+		t.Error("expected expired; got not")
+	}
+	s.Set("c", Record{Count: 3, Expires: now.Add(time.Hour)})
+	now = now.Add(time.Hour)
+	s.Set("d", Record{Count: 4, Expires: now.Add(time.Hour)})
+	if n := len(s.m); n != 1 {
+		t.Errorf("expected 1 record after sweep; got %d", n)
+	}
+	s.Delete("d")
+	if _, ok := s.Get("d"); ok {
+		t.Error("expected deleted; got not")
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	p := New(time.Minute, 5, nil)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	for _, id := range []string{"a", "b", "c"} {
+		p.Compare(id, "password", "wrongpassword")
+	}
+	now = now.Add(30 * time.Second)
+	p.Compare("d", "password", "wrongpassword")
+	now = now.Add(45 * time.Second)
+	if n := p.PurgeExpired(); n != 3 {
+		t.Errorf("expected 3 purged; got %d", n)
+	}
+	if n := len(p.cache.(*memoryStore).m); n != 1 {
+		t.Errorf("expected 1 record left; got %d", n)
+	}
+}
+
+type mapStore map[any]Record
+
+func (s mapStore) Get(id any) (Record, bool) {
+	v, ok := s[id]
+	if ok && !time.Now().Before(v.Expires) {
+		return Record{}, false
+	}
+	return v, ok
+}
+func (s mapStore) Set(id any, v Record) { s[id] = v }
+func (s mapStore) Delete(id any)        { delete(s, id) }
+
+func TestSetStore(t *testing.T) {
+	s := make(mapStore)
+	p := New(time.Minute, 2, nil)
+	p.SetStore(s)
+	p.Compare("id", "password", "wrongpassword")
+	if v := s["id"]; v.Count != 1 || v.Expires.IsZero() {
+		t.Errorf("expected record in store; got %+v", v)
+	}
+	p.Compare("id", "password", "wrongpassword")
+	if !p.IsMaxAttempts("id") {
+		t.Error("expected max attempts; got not")
+	}
+	if n := p.PurgeExpired(); n != 0 {
+		t.Errorf("expected 0; got %d", n)
+	}
+	if m := p.Snapshot(); len(m) != 0 {
+		t.Errorf("expected empty snapshot from store without All; got %v", m)
+	}
+	p.Reset("id")
+	if len(s) != 0 {
+		t.Errorf("expected empty store; got %v", s)
+	}
+
+	SetDefaultStore(s)
+	defer SetDefaultStore(nil)
+	Compare("id", "password", "wrongpassword")
+	if v := s["id"]; v.Count != 1 {
+		t.Errorf("expected default store to be used; got %+v", v)
+	}
+	Reset("id")
+}
+
+func TestMaxTrackedIDs(t *testing.T) {
+	l := NewAttemptLimiter(24*time.Hour, 5)
+	l.SetMaxTrackedIDs(2)
+	l.Fail("a")
+	l.Fail("b")
+	l.Attempts("a")
+	l.Fail("c")
+	if _, ok := l.Attempts("b"); ok {
+		t.Error("expected least recently used id evicted")
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, ok := l.Attempts(id); !ok {
+			t.Errorf("expected %s kept", id)
+		}
+	}
+	l.SetMaxTrackedIDs(1)
+	if n := len(l.Snapshot()); n != 1 {
+		t.Errorf("expected 1 id after lowering the bound; got %d", n)
+	}
+	l.SetStore(nil)
+	l.Fail("a")
+	l.Fail("b")
+	if n := len(l.Snapshot()); n != 1 {
+		t.Errorf("expected bound kept by a new memory store; got %d", n)
+	}
+}
+
+// counterStore is a mapStore safe for concurrent use with atomic increments.
+type counterStore struct {
+	mu   sync.Mutex
+	m    mapStore
+	fail bool
+
+	uncapped bool // ignores max, as a store may
+}
+
+func (s *counterStore) Get(id any) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Get(id)
+}
+
+func (s *counterStore) Set(id any, v Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m.Set(id, v)
+}
+
+func (s *counterStore) Delete(id any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m.Delete(id)
+}
+
+func (s *counterStore) Increment(id any, delta, max int, fail Record) (Record, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return Record{}, 0, errors.New("store unavailable")
+	}
+	v, ok := s.m.Get(id)
+	if !ok {
+		v = Record{FirstFail: fail.FirstFail, Expires: fail.Expires}
+	}
+	prev := v.Count
+	v.Count += delta
+	if !s.uncapped && max > 0 && v.Count > max {
+		v.Count = max
+	}
+	v.LastFail, v.LastSource = fail.LastFail, fail.LastSource
+	s.m[id] = v
+	return v, prev, nil
+}
+
+func TestIncrementer(t *testing.T) {
+	s := &counterStore{m: make(mapStore)}
+	a, b := NewAttemptLimiter(time.Minute, 1000), NewAttemptLimiter(time.Minute, 1000)
+	a.SetStore(s)
+	b.SetStore(s)
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(2)
+		go func() { defer wg.Done(); a.Fail("id") }()
+		go func() { defer wg.Done(); b.Fail("id") }()
+	}
+	wg.Wait()
+	if v, _ := s.Get("id"); v.Count != 100 {
+		t.Errorf("expected 100 attempts; got %d", v.Count)
+	}
+
+	l := NewAttemptLimiter(time.Minute, 2)
+	l.SetStore(s)
+	var locked int
+	l.SetOnLock(func(any) { locked++ })
+	l.Fail("other")
+	if n := l.Fail("other"); n != 2 || locked != 1 || !l.IsMaxAttempts("other") {
+		t.Errorf("expected locked at 2 attempts; got %d, %d", n, locked)
+	}
+	if n := l.Fail("other"); n != 2 || locked != 1 {
+		t.Errorf("expected capped at 2 attempts and locked once; got %d, %d", n, locked)
+	}
+	if v, _ := s.Get("other"); v.Count != 2 {
+		t.Errorf("expected 2 attempts in store; got %d", v.Count)
+	}
+	s.uncapped = true
+	if n := l.Fail("other"); n != 2 {
+		t.Errorf("expected count clamped at 2; got %d", n)
+	}
+	s.fail = true
+	if n := l.Fail("fallback"); n != 1 {
+		t.Errorf("expected fallback to get and set; got %d", n)
+	}
+}
+
+func TestIncrementerRecord(t *testing.T) {
+	now := time.Now()
+	l := NewAttemptLimiter(time.Minute, 5)
+	l.now = func() time.Time { return now }
+	l.SetStore(&counterStore{m: make(mapStore)})
+	l.record("id", 1, 5, "192.0.2.1")
+	start := now
+	now = now.Add(10 * time.Second)
+	l.record("id", 1, 5, "192.0.2.2")
+	if at, ok := l.FailureWindowStart("id"); !ok || !at.Equal(start) {
+		t.Errorf("expected failure window start %s; got %s, %v", start, at, ok)
+	}
+	if v, _ := l.Attempts("id"); v.Count != 2 || !v.LastFail.Equal(now) || v.LastSource != "192.0.2.2" {
+		t.Errorf("unexpected record: %+v", v)
+	}
+}
@@ -0,0 +1,132 @@
+package password
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned when a password token is malformed, not signed by the
+// configured key or expired, see SetTokenKey.
+var ErrInvalidToken = errors.New("invalid password token")
+
+type tokenConfig struct {
+	key   any
+	claim string
+}
+
+// SetTokenKey makes comparisons take the password as a JWT, e.g. issued by a single-page
+// application, and compare its claim named claim instead. key verifies the signature:
+// a []byte shared secret for HS256, an *rsa.PublicKey for RS256 or an ed25519.PublicKey
+// for EdDSA, the token must use the algorithm of the key. The token must carry an exp
+// claim, and nbf is honored if present. The claim is then decrypted with the configured
+// key if any, as a password would be. Invalid tokens fail with ErrInvalidToken without
+// counting an attempt. nil key disables it.
+func (p *Passworder) SetTokenKey(key any, claim string) {
+	if key == nil {
+		p.token = nil
+		return
+	}
+	p.token = &tokenConfig{key: key, claim: claim}
+}
+
+// parseToken verifies token and returns its password claim.
+func (p *Passworder) parseToken(token string) (string, error) {
+	header, rest, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	payload, sig, ok := strings.Cut(rest, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeTokenPart(header, &h); err != nil {
+		return "", err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if err := verifyToken(p.token.key, h.Alg, header+"."+payload, signature); err != nil {
+		return "", err
+	}
+	var claims map[string]any
+	if err := decodeTokenPart(payload, &claims); err != nil {
+		return "", err
+	}
+	now := p.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return "", fmt.Errorf("%w: no expiry", ErrInvalidToken)
+	}
+	if !now.Before(time.Unix(int64(exp), 0)) {
+		return "", fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0)) {
+		return "", fmt.Errorf("%w: not yet valid", ErrInvalidToken)
+	}
+	password, ok := claims[p.token.claim].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: no %s claim", ErrInvalidToken, p.token.claim)
+	}
+	return password, nil
+}
+
+func decodeTokenPart(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// verifyToken checks the signature of signed, alg must be the one of the key type
+// so that e.g. a public key cannot be used as an HMAC secret.
+func verifyToken(key any, alg, signed string, sig []byte) error {
+	switch key := key.(type) {
+	case []byte:
+		if alg != "HS256" {
+			break
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrInvalidToken
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			break
+		}
+		sum := sha256.Sum256([]byte(signed))
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			break
+		}
+		if !ed25519.Verify(key, []byte(signed), sig) {
+			return ErrInvalidToken
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported token key type %T", key)
+	}
+	return fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidToken, alg)
+}
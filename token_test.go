@@ -0,0 +1,75 @@
+package password
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func signToken(t *testing.T, alg string, claims map[string]any, sign func(string) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return s + "." + base64.RawURLEncoding.EncodeToString(sign(s))
+}
+
+func TestTokenKey(t *testing.T) {
+	now := time.Now()
+	secret := []byte("shared secret")
+	hs256 := func(s string) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(s))
+		return mac.Sum(nil)
+	}
+	p := New(24*time.Hour, 5, nil)
+	p.now = func() time.Time { return now }
+	p.SetTokenKey(secret, "pwd")
+
+	valid := signToken(t, "HS256", map[string]any{"pwd": "password", "exp": now.Add(time.Minute).Unix()}, hs256)
+	if err := p.Compare("id", "password", valid); err != nil {
+		t.Fatal(err)
+	}
+	wrong := signToken(t, "HS256", map[string]any{"pwd": "wrongpassword", "exp": now.Add(time.Minute).Unix()}, hs256)
+	if err := p.Compare("id", "password", wrong); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+	for name, token := range map[string]string{
+		"expired":   signToken(t, "HS256", map[string]any{"pwd": "password", "exp": now.Add(-time.Minute).Unix()}, hs256),
+		"no expiry": signToken(t, "HS256", map[string]any{"pwd": "password"}, hs256),
+		"forged":    signToken(t, "HS256", map[string]any{"pwd": "password", "exp": now.Add(time.Minute).Unix()}, func(string) []byte { return []byte("forged") }),
+		"none":      signToken(t, "none", map[string]any{"pwd": "password", "exp": now.Add(time.Minute).Unix()}, func(string) []byte { return nil }),
+		"malformed": "password",
+	} {
+		if err := p.Compare("other", "password", token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken; got %v", name, err)
+		}
+	}
+	if _, ok := p.Attempts("other"); ok {
+		t.Error("expected invalid tokens not counted")
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetTokenKey(pub, "pwd")
+	eddsa := signToken(t, "EdDSA", map[string]any{"pwd": "password", "exp": now.Add(time.Minute).Unix()}, func(s string) []byte {
+		return ed25519.Sign(priv, []byte(s))
+	})
+	if err := p.Compare("id", "password", eddsa); err != nil {
+		t.Error(err)
+	}
+	if err := p.Compare("id", "password", valid); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected HS256 token rejected with a public key; got %v", err)
+	}
+}
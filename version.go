@@ -0,0 +1,59 @@
+package password
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidVersionTag is returned when the version tag of a hash is malformed.
+var ErrInvalidVersionTag = errors.New("invalid hash version tag")
+
+// SetHashVersion sets the version HashVersioned tags hashes with, to be raised whenever
+// the hashing scheme or policy changes, e.g. a new algorithm, cost or pepper, so that
+// CompareVersioned reports hashes made before. The default version is 1.
+func (p *Passworder) SetHashVersion(version int) { p.version = version }
+
+func (p *Passworder) hashVersion() int {
+	if p.version <= 0 {
+		return 1
+	}
+	return p.version
+}
+
+// HashVersioned is like HashPassword, but tags the hash with the current version,
+// as in v2:$2a$..., see SetHashVersion.
+func (p *Passworder) HashVersioned(password string) (string, error) {
+	hash, err := p.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+	return "v" + strconv.Itoa(p.hashVersion()) + ":" + hash, nil
+}
+
+// ParseVersioned returns the version tag of a hash made by HashVersioned and the hash
+// without it. An untagged hash is returned as is with version 0.
+func ParseVersioned(hash string) (version int, untagged string, err error) {
+	tag, rest, ok := strings.Cut(hash, ":")
+	if !ok || !strings.HasPrefix(tag, "v") {
+		return 0, hash, nil
+	}
+	if version, err = strconv.Atoi(tag[1:]); err != nil || version <= 0 || tag[1] == '0' {
+		return 0, "", ErrInvalidVersionTag
+	}
+	return version, rest, nil
+}
+
+// CompareVersioned is like CompareAndUpgrade for a hash made by HashVersioned or an
+// untagged hash, rehash also reports hashes of a version other than the current one,
+// which should be replaced by HashVersioned(password).
+func (p *Passworder) CompareVersioned(id any, hash, password string) (rehash bool, err error) {
+	version, hash, err := ParseVersioned(hash)
+	if err != nil {
+		return false, err
+	}
+	if rehash, err = p.CompareAndUpgrade(id, hash, password); err != nil {
+		return false, err
+	}
+	return rehash || version != p.hashVersion(), nil
+}
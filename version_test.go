@@ -0,0 +1,52 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashVersioned(t *testing.T) {
+	p := New(24*time.Hour, 5, nil)
+	p.SetHashVersion(2)
+	hash, err := p.HashVersioned("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "v2:$2a$") {
+		t.Errorf("expected v2 tagged bcrypt hash; got %s", hash)
+	}
+	if version, untagged, err := ParseVersioned(hash); err != nil || version != 2 || untagged != hash[3:] {
+		t.Errorf("expected version 2; got %d, %q, %v", version, untagged, err)
+	}
+	if rehash, err := p.CompareVersioned("id", hash, "password"); err != nil || rehash {
+		t.Errorf("expected current hash; got %v, %v", rehash, err)
+	}
+	if v, err := Hash(hash).Value(); err != nil || v != hash {
+		t.Errorf("expected versioned hash stored as is; got %v, %v", v, err)
+	}
+	if alg, err := Algorithm(hash); err != nil || alg != "bcrypt" || !IsValidHash(hash) {
+		t.Errorf("expected valid bcrypt hash; got %q, %v", alg, err)
+	}
+	if _, err := p.CompareVersioned("id", hash, "wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Errorf("expected ErrIncorrectPassword; got %v", err)
+	}
+
+	p.SetHashVersion(3)
+	if rehash, err := p.CompareVersioned("id", hash, "password"); err != nil || !rehash {
+		t.Errorf("expected rehash of previous version; got %v, %v", rehash, err)
+	}
+	untagged, err := p.HashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rehash, err := p.CompareVersioned("id", untagged, "password"); err != nil || !rehash {
+		t.Errorf("expected rehash of untagged hash; got %v, %v", rehash, err)
+	}
+	for _, hash := range []string{"v:$2a$", "v0:$2a$", "vx:$2a$", "v-1:$2a$"} {
+		if _, _, err := ParseVersioned(hash); err != ErrInvalidVersionTag {
+			t.Errorf("%s: expected ErrInvalidVersionTag; got %v", hash, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package password
+
+import (
+	"slices"
+	"time"
+)
+
+// WindowBucket is the failed attempts of a sub-window in sliding window mode.
+type WindowBucket struct {
+	Start time.Time
+	Count int
+}
+
+// SetSlidingWindow switches the lockout from a fixed window counter to a sliding window
+// of total split into buckets sub-windows: failures are counted in the current bucket,
+// and the count of an id is the sum of the buckets within the last total. The count so
+// decreases bucket by bucket as failures age, instead of resetting all at once when the
+// fixed window expires, which lets an attacker try twice the maximum attempts around
+// the reset. total replaces the duration. Zero buckets switches back to the fixed
+// window counter.
+func (l *AttemptLimiter) SetSlidingWindow(buckets int, total time.Duration) {
+	l.buckets = buckets
+	l.dur = total
+}
+
+// slideRecord drops the buckets which left the window and recounts the record.
+func (l *AttemptLimiter) slideRecord(v *Record, now time.Time) {
+	if l.buckets <= 0 || v.Window == nil {
+		return
+	}
+	i := 0
+	for i < len(v.Window) && !now.Before(v.Window[i].Start.Add(l.dur)) {
+		i++
+	}
+	v.Window = v.Window[i:]
+	v.Count = 0
+	for _, b := range v.Window {
+		v.Count += b.Count
+	}
+}
+
+// addToWindow adds n failures to the current bucket of the record.
+func (l *AttemptLimiter) addToWindow(v *Record, now time.Time, n int) {
+	if n <= 0 {
+		return
+	}
+	// The buckets may be shared with the stored record.
+	v.Window = slices.Clone(v.Window)
+	start := now.Truncate(l.dur / time.Duration(l.buckets))
+	if last := len(v.Window) - 1; last >= 0 && v.Window[last].Start.Equal(start) {
+		v.Window[last].Count += n
+	} else {
+		v.Window = append(v.Window, WindowBucket{Start: start, Count: n})
+	}
+	v.Expires = start.Add(l.dur)
+}
+
+// removeFromWindow removes n failures from the oldest buckets of the record.
+func (l *AttemptLimiter) removeFromWindow(v *Record, n int) {
+	v.Window = slices.Clone(v.Window)
+	for n > 0 && len(v.Window) > 0 {
+		d := min(n, v.Window[0].Count)
+		v.Window[0].Count -= d
+		n -= d
+		if v.Window[0].Count == 0 {
+			v.Window = v.Window[1:]
+		}
+	}
+}
+
+// windowRetryAfter returns how long until enough buckets leave the window for the
+// record to drop below max attempts.
+func (l *AttemptLimiter) windowRetryAfter(v Record, now time.Time, max int) time.Duration {
+	count := v.Count
+	for _, b := range v.Window {
+		if count -= b.Count; count < max {
+			return b.Start.Add(l.dur).Sub(now)
+		}
+	}
+	return 0
+}